@@ -0,0 +1,129 @@
+// Command citylimits-sim runs the City Limits simulation without opening a
+// window and reports statistics for each month simulated. It does not depend
+// on a display, so it may be run on servers and in CI.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"code.rocketnine.space/tslocum/citylimits/system"
+	"code.rocketnine.space/tslocum/citylimits/world"
+)
+
+func main() {
+	err := run(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs the simulation and reports statistics for each month simulated.
+func run(args []string) error {
+	var (
+		cityFile   string
+		mapSize    string
+		replayFile string
+		reportFile string
+		years      int
+	)
+	flags := flag.NewFlagSet("citylimits-sim", flag.ExitOnError)
+	flags.StringVar(&cityFile, "city", "", "city to simulate (an empty city is simulated when unset)")
+	flags.StringVar(&replayFile, "replay", "", "replay to simulate until it ends (overrides -city and -years)")
+	flags.IntVar(&years, "years", 10, "number of years to simulate")
+	flags.StringVar(&reportFile, "report", "", "write report to CSV file instead of standard output")
+	flags.Int64Var(&world.World.MapSeed, "seed", 0, "seed of the random number generator (random when 0)")
+	flags.StringVar(&mapSize, "size", strconv.Itoa(world.DefaultMapSize), "size of the empty city (SIZE or WIDTHxHEIGHT)")
+	flags.StringVar(&world.World.TerrainPreset, "terrain", world.DefaultTerrainPreset, "terrain of the empty city ("+strings.Join(world.TerrainPresetNames(), ", ")+")")
+	flags.Parse(args)
+
+	var err error
+	world.World.MapWidth, world.World.MapHeight, err = world.ParseMapSize(mapSize)
+	if err != nil {
+		return err
+	}
+	err = world.ValidateTerrainPreset(world.World.TerrainPreset)
+	if err != nil {
+		return err
+	}
+
+	sim := system.NewSimulation()
+
+	if replayFile != "" {
+		err := world.LoadReplayFile(replayFile)
+		if err != nil {
+			return fmt.Errorf("failed to load replay %s: %s", replayFile, err)
+		}
+	} else if cityFile != "" {
+		err := world.LoadCityFile(cityFile)
+		if err != nil {
+			return fmt.Errorf("failed to load city %s: %s", cityFile, err)
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if reportFile != "" {
+		f, err := os.Create(reportFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := csv.NewWriter(out)
+	err = w.Write([]string{"year", "month", "population", "residential", "commercial", "industrial", "funds", "zones", "powered_zones", "power_available", "power_needed", "power_lost"})
+	if err != nil {
+		return err
+	}
+
+	endTicks := world.World.Ticks + years*world.YearTicks
+	if world.World.Replaying {
+		endTicks = world.World.ReplayEnd
+	}
+	for world.World.Ticks < endTicks {
+		err = sim.Update()
+		if err != nil {
+			return err
+		}
+
+		if world.World.Ticks%world.MonthTicks != 0 {
+			continue
+		}
+
+		popR, popC, popI := world.Population()
+		var poweredZones int
+		for _, zone := range world.World.Zones {
+			if zone.Powered {
+				poweredZones++
+			}
+		}
+		month, year := world.Date()
+		err = w.Write([]string{
+			year,
+			month,
+			strconv.Itoa(popR + popC + popI),
+			strconv.Itoa(popR),
+			strconv.Itoa(popC),
+			strconv.Itoa(popI),
+			strconv.Itoa(world.World.Funds),
+			strconv.Itoa(len(world.World.Zones)),
+			strconv.Itoa(poweredZones),
+			strconv.Itoa(world.World.PowerAvailable),
+			strconv.Itoa(world.World.PowerNeeded),
+			strconv.Itoa(world.World.PowerLost),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestHeadless verifies that the simulation does not depend on ebiten, which
+// connects to a display when it is initialized.
+func TestHeadless(t *testing.T) {
+	out, err := exec.Command("go", "list", "-deps", ".").Output()
+	if err != nil {
+		t.Skipf("failed to list dependencies: %s", err)
+	}
+	for _, dep := range strings.Fields(string(out)) {
+		if strings.HasPrefix(dep, "github.com/hajimehoshi/ebiten") {
+			t.Fatalf("simulation depends on %s", dep)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	os.Unsetenv("DISPLAY")
+
+	reportFile := filepath.Join(t.TempDir(), "report.csv")
+	err := run([]string{"-seed", "1", "-size", "32", "-years", "1", "-report", reportFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+	}
+	if lines != 13 {
+		t.Errorf("expected header and 12 months, got %d lines", lines)
+	}
+}
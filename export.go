@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"code.rocketnine.space/tslocum/citylimits/system"
+	"code.rocketnine.space/tslocum/citylimits/world"
+)
+
+// runExport renders a saved city to a PNG file, or exports it to a Tiled map,
+// without opening a window. When no city is specified, a new map is generated
+// so that terrain presets may be previewed.
+func runExport(args []string) error {
+	var (
+		cityFile  string
+		imageFile string
+		mapSize   string
+		zoom      float64
+	)
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.StringVar(&cityFile, "city", "", "city to export (a new map is generated when unset)")
+	flags.StringVar(&imageFile, "o", "city.png", "write image to PNG file (or Tiled map when the file name ends with .tmx)")
+	flags.Float64Var(&zoom, "zoom", world.DefaultExportZoom, "zoom level (up to 1)")
+	flags.Int64Var(&world.World.MapSeed, "seed", 0, "seed of the generated map (random when 0)")
+	flags.StringVar(&mapSize, "size", strconv.Itoa(world.DefaultMapSize), "size of the generated map (SIZE or WIDTHxHEIGHT)")
+	flags.StringVar(&world.World.TerrainPreset, "terrain", world.DefaultTerrainPreset, "terrain of the generated map ("+strings.Join(world.TerrainPresetNames(), ", ")+")")
+	flags.Parse(args)
+
+	var err error
+	world.World.MapWidth, world.World.MapHeight, err = world.ParseMapSize(mapSize)
+	if err != nil {
+		return err
+	}
+	err = world.ValidateTerrainPreset(world.World.TerrainPreset)
+	if err != nil {
+		return err
+	}
+
+	system.NewSimulation()
+
+	if cityFile != "" {
+		err = world.LoadCityFile(cityFile)
+		if err != nil {
+			return fmt.Errorf("failed to load city %s: %s", cityFile, err)
+		}
+	}
+
+	if strings.EqualFold(filepath.Ext(imageFile), ".tmx") {
+		return world.ExportTMXFile(imageFile)
+	}
+	return world.ExportImageFile(imageFile, zoom)
+}
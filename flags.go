@@ -5,6 +5,9 @@ package main
 
 import (
 	"flag"
+	"log"
+	"strconv"
+	"strings"
 
 	"code.rocketnine.space/tslocum/citylimits/world"
 	"github.com/hajimehoshi/ebiten/v2"
@@ -14,14 +17,32 @@ func parseFlags() {
 	var (
 		fullscreen bool
 		noSplash   bool
+		mapSize    string
 	)
 	flag.BoolVar(&fullscreen, "fullscreen", false, "run in fullscreen mode")
 	flag.BoolVar(&world.World.NativeResolution, "native", false, "display at native resolution")
 	flag.BoolVar(&noSplash, "no-splash", false, "skip splash screen")
 	flag.BoolVar(&world.World.MuteMusic, "mute-music", false, "mute music")
 	flag.IntVar(&world.World.Debug, "debug", 0, "print debug information")
+	flag.StringVar(&world.World.LoadFile, "load", "", "load city from file")
+	flag.IntVar(&world.World.AutosaveMonths, "autosave", 3, "months between autosaves (0 to save only when exiting)")
+	flag.Int64Var(&world.World.MapSeed, "seed", 0, "seed of the random number generator (random when 0)")
+	flag.StringVar(&mapSize, "size", strconv.Itoa(world.DefaultMapSize), "size of new maps (SIZE or WIDTHxHEIGHT)")
+	flag.StringVar(&world.World.TerrainPreset, "terrain", world.DefaultTerrainPreset, "terrain of new maps ("+strings.Join(world.TerrainPresetNames(), ", ")+")")
+	flag.StringVar(&world.World.RecordFile, "record", "", "record replay to file (default last.replay in the save directory)")
+	flag.StringVar(&world.World.ReplayFile, "replay", "", "play back replay from file (Space to pause, Tab to fast-forward)")
 	flag.Parse()
 
+	var err error
+	world.World.MapWidth, world.World.MapHeight, err = world.ParseMapSize(mapSize)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = world.ValidateTerrainPreset(world.World.TerrainPreset)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	if fullscreen {
 		ebiten.SetFullscreen(true)
 	}
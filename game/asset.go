@@ -0,0 +1,162 @@
+package game
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	_ "image/png"
+	"io"
+
+	"code.rocketnine.space/tslocum/citylimits/asset"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+	"github.com/hajimehoshi/ebiten/v2/audio/vorbis"
+	"github.com/hajimehoshi/ebiten/v2/audio/wav"
+)
+
+var (
+	imgBlank       = ebiten.NewImage(1, 1)
+	imgWhiteSquare = ebiten.NewImage(64, 64)
+	imgBlackSquare = ebiten.NewImage(64, 64)
+	imgHelp        = loadImage("image/help.png")
+	imgPower       = loadImage("image/power.png")
+	imgBrownout    = loadImage("image/brownout.png")
+)
+
+var (
+	soundMusic1 *audio.Player
+	soundMusic2 *audio.Player
+	soundMusic3 *audio.Player
+
+	soundSelect   *audio.Player
+	soundBulldoze *audio.Player
+
+	soundPop1 *audio.Player
+	soundPop2 *audio.Player
+	soundPop3 *audio.Player
+	soundPop4 *audio.Player
+	soundPop5 *audio.Player
+
+	soundExplosion1 *audio.Player
+	soundExplosion2 *audio.Player
+)
+
+func init() {
+	imgWhiteSquare.Fill(color.White)
+	imgBlackSquare.Fill(color.Black)
+}
+
+func loadSounds(ctx *audio.Context) {
+	soundMusic1 = loadOGG(ctx, "sound/we_will_build_it.ogg", false)
+	soundMusic1.SetVolume(0.6)
+
+	soundMusic2 = loadOGG(ctx, "sound/please_recycle.ogg", false)
+	soundMusic2.SetVolume(0.1)
+
+	soundMusic3 = loadOGG(ctx, "sound/the_world_is_a_landfill_and_its_your_fault_you_fucking_son_of_a_bitch_god_damn.ogg", false)
+	soundMusic3.SetVolume(0.4)
+
+	soundSelect = loadWAV(ctx, "sound/select/select.wav")
+	soundSelect.SetVolume(0.6)
+
+	soundBulldoze = loadOGG(ctx, "sound/bulldozer/bulldozer.ogg", true)
+	soundBulldoze.SetVolume(0.6)
+
+	const popVolume = 0.15
+	soundPop1 = loadWAV(ctx, "sound/pop/pop1.wav")
+	soundPop2 = loadWAV(ctx, "sound/pop/pop2.wav")
+	soundPop3 = loadWAV(ctx, "sound/pop/pop3.wav")
+	soundPop4 = loadWAV(ctx, "sound/pop/pop4.wav")
+	soundPop5 = loadWAV(ctx, "sound/pop/pop5.wav")
+	soundPop1.SetVolume(popVolume)
+	soundPop2.SetVolume(popVolume)
+	soundPop3.SetVolume(popVolume)
+	soundPop4.SetVolume(popVolume)
+	soundPop5.SetVolume(popVolume)
+
+	const explosionVolume = 0.1
+	soundExplosion1 = loadOGG(ctx, "sound/explosion/explosion1.ogg", false)
+	soundExplosion2 = loadOGG(ctx, "sound/explosion/explosion2.ogg", false)
+	soundExplosion1.SetVolume(explosionVolume)
+	soundExplosion2.SetVolume(explosionVolume)
+}
+
+func loadImage(p string) *ebiten.Image {
+	f, err := asset.FS.Open(p)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	baseImg, _, err := image.Decode(f)
+	if err != nil {
+		panic(err)
+	}
+
+	return ebiten.NewImageFromImage(baseImg)
+}
+
+func loadBytes(p string) []byte {
+	b, err := asset.FS.ReadFile(p)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func loadWAV(context *audio.Context, p string) *audio.Player {
+	f, err := asset.FS.Open(p)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	stream, err := wav.DecodeWithSampleRate(sampleRate, f)
+	if err != nil {
+		panic(err)
+	}
+
+	player, err := context.NewPlayer(stream)
+	if err != nil {
+		panic(err)
+	}
+
+	// Workaround to prevent delays when playing for the first time.
+	player.SetVolume(0)
+	player.Play()
+	player.Pause()
+	player.Rewind()
+	player.SetVolume(1)
+
+	return player
+}
+
+func loadOGG(context *audio.Context, p string, loop bool) *audio.Player {
+	b := loadBytes(p)
+
+	stream, err := vorbis.DecodeWithSampleRate(sampleRate, bytes.NewReader(b))
+	if err != nil {
+		panic(err)
+	}
+
+	var s io.Reader
+	if loop {
+		s = audio.NewInfiniteLoop(stream, stream.Length())
+	} else {
+		s = stream
+	}
+
+	player, err := context.NewPlayer(s)
+	if err != nil {
+		panic(err)
+	}
+
+	// Workaround to prevent delays when playing for the first time.
+	player.SetVolume(0)
+	player.Play()
+	player.Pause()
+	player.Rewind()
+	player.SetVolume(1)
+
+	return player
+}
@@ -1,16 +1,17 @@
 package game
 
 import (
+	"fmt"
 	"image/color"
 	"math/rand"
 	"os"
 	"sync"
+	"time"
 
 	"code.rocketnine.space/tslocum/gohan"
 
 	"code.rocketnine.space/tslocum/citylimits/entity"
 
-	"code.rocketnine.space/tslocum/citylimits/asset"
 	"code.rocketnine.space/tslocum/citylimits/system"
 	"code.rocketnine.space/tslocum/citylimits/world"
 	"github.com/hajimehoshi/ebiten/v2"
@@ -19,6 +20,9 @@ import (
 
 const sampleRate = 44100
 
+// player is the entity matched by the game's systems.
+var player gohan.Entity
+
 // game is an isometric demo game.
 type game struct {
 	w, h int
@@ -32,11 +36,13 @@ type game struct {
 	debugMode  bool
 	cpuProfile *os.File
 
-	movementSystem *system.MovementSystem
-	renderSystem   *system.RenderSystem
+	movementSystem *MovementSystem
+	renderSystem   *RenderSystem
 
 	addedSystems bool
 
+	simulation *simulationSystem
+
 	updateTicks int
 
 	sync.Mutex
@@ -49,6 +55,10 @@ func NewGame() (*game, error) {
 		op:           &ebiten.DrawImageOptions{},
 	}
 
+	// Cosmetic randomness (sound and music selection) does not use the world
+	// seed, so that it does not affect the simulation.
+	rand.Seed(time.Now().UnixNano())
+
 	err := g.loadAssets()
 	if err != nil {
 		panic(err)
@@ -71,8 +81,18 @@ func (g *game) Layout(w, h int) (int, int) {
 }
 
 func (g *game) Update() error {
-	if ebiten.IsWindowBeingClosed() {
-		g.Exit()
+	g.Lock()
+	defer g.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			world.EmergencySave()
+			panic(r)
+		}
+	}()
+
+	if ebiten.IsWindowBeingClosed() || world.World.ExitGame {
+		g.exit()
 		return nil
 	}
 
@@ -85,95 +105,118 @@ func (g *game) Update() error {
 	}
 
 	if world.World.ResetGame {
+		for _, e := range gohan.AllEntities() {
+			e.Remove()
+		}
+		player = 0
+
 		world.Reset()
+		playingSong = rand.Intn(3)
 
-		err := world.LoadTileset()
+		err := loadTileset()
 		if err != nil {
 			return err
 		}
 
-		// Fill below ground layer.
-		var img uint32
-		for x := range world.World.Level.Tiles[0] {
-			for y := range world.World.Level.Tiles[0][x] {
-				img = world.DirtTile
-				if rand.Intn(150) == 0 {
-					img = world.GrassTile
-					world.World.Level.Tiles[0][x][y].EnvironmentSprite = world.World.TileImages[img+world.World.TileImagesFirstGID]
-					for offsetX := -2 - rand.Intn(7); offsetX < 2+rand.Intn(7); offsetX++ {
-						for offsetY := -2 - rand.Intn(7); offsetY < 2+rand.Intn(7); offsetY++ {
-							if x+offsetX >= 0 && y+offsetY >= 0 && x+offsetX < 256 && y+offsetY < 256 {
-								world.World.Level.Tiles[0][x+offsetX][y+offsetY].EnvironmentSprite = world.World.TileImages[img+world.World.TileImagesFirstGID]
-								if rand.Intn(4) == 0 {
-									if rand.Intn(3) == 0 {
-										world.World.Level.Tiles[1][x+offsetX][y+offsetY].EnvironmentSprite = world.World.TileImages[world.TreeTileA+world.World.TileImagesFirstGID]
-									} else {
-										world.World.Level.Tiles[1][x+offsetX][y+offsetY].EnvironmentSprite = world.World.TileImages[world.TreeTileB+world.World.TileImagesFirstGID]
-									}
-								}
-							}
-						}
-					}
-				} else {
-					if world.World.Level.Tiles[0][x][y].EnvironmentSprite != nil {
-						continue
-					}
-					world.World.Level.Tiles[0][x][y].EnvironmentSprite = world.World.TileImages[img+world.World.TileImagesFirstGID]
-				}
+		if world.World.ReplayFile != "" {
+			err := world.LoadReplayFile(world.World.ReplayFile)
+			if err != nil {
+				return fmt.Errorf("failed to load replay %s: %s", world.World.ReplayFile, err)
+			}
+			world.World.ReplayFile = ""
+		} else if world.World.LoadFile != "" {
+			err := world.LoadCityFile(world.World.LoadFile)
+			if err != nil {
+				return fmt.Errorf("failed to load city %s: %s", world.World.LoadFile, err)
 			}
+			world.World.LoadFile = ""
+		} else {
+			world.GenerateTerrain()
+			world.BeginCity()
 		}
+		world.StartRecording()
 
 		// Load HUD sprites.
 
-		transparentBuilding := world.DrawMap(world.StructureCommercialHigh)
+		transparentBuilding := drawMap(world.StructureCommercialHigh)
 		transparentImg := ebiten.NewImage(transparentBuilding.Bounds().Dx(), transparentBuilding.Bounds().Dy())
 		op := &ebiten.DrawImageOptions{}
 		op.ColorM.Scale(1, 1, 1, 0.4)
 		transparentImg.DrawImage(transparentBuilding, op)
-		world.HUDButtons = []*world.HUDButton{
+		hudButtons = []*hudButton{
 			{
 				StructureType: world.StructureBulldozer,
-				Sprite:        world.DrawMap(world.StructureBulldozer),
+				Sprite:        drawMap(world.StructureBulldozer),
 				SpriteOffsetX: 12,
 				SpriteOffsetY: -48,
 			},
 			nil,
 			{
 				StructureType: world.StructureRoad,
-				Sprite:        world.DrawMap(world.StructureRoad),
+				Sprite:        drawMap(world.StructureRoad),
 				SpriteOffsetX: -12,
 				SpriteOffsetY: -28,
 			},
 			{
 				StructureType: world.StructureResidentialZone,
-				Sprite:        world.DrawMap(world.StructureResidentialLow),
+				Sprite:        drawMap(world.StructureResidentialLow),
 				SpriteOffsetX: -12,
 				SpriteOffsetY: -28,
 			}, {
 				StructureType: world.StructureCommercialZone,
-				Sprite:        world.DrawMap(world.StructureCommercialLow),
+				Sprite:        drawMap(world.StructureCommercialLow),
 				SpriteOffsetX: -10,
 				SpriteOffsetY: -28,
 			}, {
 				StructureType: world.StructureIndustrialZone,
-				Sprite:        world.DrawMap(world.StructureIndustrialLow),
+				Sprite:        drawMap(world.StructureIndustrialLow),
 				SpriteOffsetX: -10,
 				SpriteOffsetY: -28,
 			}, {
 				StructureType: world.StructurePowerPlantCoal,
 				SpriteOffsetX: -20,
 				SpriteOffsetY: 2,
-				Sprite:        world.DrawMap(world.StructurePowerPlantCoal),
+				Sprite:        drawMap(world.StructurePowerPlantCoal),
 			}, {
 				StructureType: world.StructurePowerPlantSolar,
 				SpriteOffsetX: -20,
 				SpriteOffsetY: 2,
-				Sprite:        world.DrawMap(world.StructurePowerPlantSolar),
+				Sprite:        drawMap(world.StructurePowerPlantSolar),
 			}, {
 				StructureType: world.StructurePowerPlantNuclear,
 				SpriteOffsetX: -20,
 				SpriteOffsetY: 2,
-				Sprite:        world.DrawMap(world.StructurePowerPlantNuclear),
+				Sprite:        drawMap(world.StructurePowerPlantNuclear),
+			}, {
+				StructureType: world.StructureRaiseTerrain,
+				Sprite:        drawMap(world.StructureRaiseTerrain),
+				SpriteOffsetX: 2,
+				SpriteOffsetY: -28,
+			}, {
+				StructureType: world.StructureLowerTerrain,
+				Sprite:        drawMap(world.StructureLowerTerrain),
+				SpriteOffsetX: 2,
+				SpriteOffsetY: -48,
+			}, {
+				StructureType: world.StructurePoliceStation,
+				Sprite:        drawMap(world.StructurePoliceStation),
+				SpriteOffsetX: -16,
+				SpriteOffsetY: -8,
+			}, {
+				StructureType: world.StructureFireStation,
+				Sprite:        drawMap(world.StructureFireStation),
+				SpriteOffsetX: -16,
+				SpriteOffsetY: -8,
+			}, {
+				StructureType: world.StructurePowerLine,
+				Sprite:        drawMap(world.StructurePowerLine),
+				SpriteOffsetX: 2,
+				SpriteOffsetY: -28,
+			}, {
+				StructureType: world.StructureHighVoltageLine,
+				Sprite:        drawMap(world.StructureHighVoltageLine),
+				SpriteOffsetX: 2,
+				SpriteOffsetY: -48,
 			},
 			nil,
 			nil,
@@ -188,15 +231,9 @@ func (g *game) Update() error {
 			nil,
 			nil,
 			nil,
-			nil,
-			nil,
-			nil,
-			nil,
-			nil,
-			nil,
 			{
 				StructureType: world.StructureToggleHelp,
-				Sprite:        asset.ImgHelp,
+				Sprite:        imgHelp,
 				SpriteOffsetX: 0,
 				SpriteOffsetY: -1,
 			},
@@ -210,8 +247,8 @@ func (g *game) Update() error {
 
 		// TODO
 
-		if world.World.Player == 0 {
-			world.World.Player = entity.NewPlayer()
+		if player == 0 {
+			player = entity.NewPlayer()
 		}
 
 		if !g.addedSystems {
@@ -228,6 +265,18 @@ func (g *game) Update() error {
 	if err != nil {
 		return err
 	}
+
+	// Fast-forward replays by advancing the simulation additional ticks
+	// without handling input or updating render systems.
+	for i := 1; i < world.World.ReplaySpeed && !world.World.Paused; i++ {
+		err = g.simulation.Update(player)
+		if err != nil {
+			return err
+		}
+	}
+
+	playSoundEffects()
+	updateMusic()
 	return nil
 }
 
@@ -305,30 +354,60 @@ func (g *game) Draw(screen *ebiten.Image) {
 				if tile == nil {
 					continue
 				}
-				var sprite *ebiten.Image
+				var gid uint32
 				colorScale := 1.0
 				alpha := 1.0
-				if tile.HoverSprite != nil {
-					sprite = tile.HoverSprite
+				if tile.HoverSprite != 0 {
+					gid = tile.HoverSprite
 					colorScale = 0.6
 					if !world.World.HoverValid {
 						colorScale = 0.2
 					}
-				} else if tile.Sprite != nil {
-					sprite = tile.Sprite
+				} else if tile.Sprite != 0 {
+					gid = tile.Sprite
 					if world.World.TransparentStructures && i > 1 {
 						alpha = 0.2
 					}
-				} else if tile.EnvironmentSprite != nil {
-					sprite = tile.EnvironmentSprite
+				} else if tile.EnvironmentSprite != 0 {
+					gid = tile.EnvironmentSprite
 				} else {
 					continue
 				}
-				drawn += g.renderSprite(float64(x), float64(y), 0, float64(i*-40), 0, 1, colorScale, alpha, false, false, sprite, screen)
+				elevation := world.World.Level.ElevationOffset(x, y)
+				if gid != world.HiddenTile {
+					sprite := tileImages[gid]
+					if sprite != nil {
+						if i == 0 {
+							world.World.Level.GroundFill(x, y, func(height int) {
+								drawn += g.renderSprite(float64(x), float64(y), 0, float64(-height*world.ElevationHeight), 0, 1, colorScale, alpha, false, false, sprite, screen)
+							})
+						}
+						drawn += g.renderSprite(float64(x), float64(y), 0, float64(i*-world.LayerHeight)+elevation, 0, 1, colorScale, alpha, false, false, sprite, screen)
+					}
+				}
 
 				// Draw power-outs.
 				if world.World.HavePowerOut && world.World.Ticks%(144*2) < int(144.0*1.5) && world.World.PowerOuts[x][y] {
-					drawn += g.renderSprite(float64(x), float64(y), 0, -52, 0, 1, 1, 1, false, false, asset.ImgPower, screen)
+					drawn += g.renderSprite(float64(x), float64(y), 0, -52+elevation, 0, 1, 1, 1, false, false, imgPower, screen)
+				} else if world.World.HaveBrownout && world.World.Ticks%(144*2) < int(144.0*1.5) && world.World.Brownouts[x][y] {
+					drawn += g.renderSprite(float64(x), float64(y), 0, -52+elevation, 0, 1, 1, 1, false, false, imgBrownout, screen)
+				}
+			}
+		}
+	}
+
+	// Draw fires.
+	if len(world.World.Fires) > 0 && world.World.Ticks%(144/2) < 144/4 {
+		sprite := tileImages[world.TileGID(world.FireTile)]
+		for _, fire := range world.World.Fires {
+			structure := world.StructureAt(fire.X, fire.Y)
+			if structure == nil || sprite == nil {
+				continue
+			}
+			r := structure.Rect()
+			for x := r.Min.X; x < r.Max.X; x++ {
+				for y := r.Min.Y; y < r.Max.Y; y++ {
+					drawn += g.renderSprite(float64(x), float64(y), 0, -world.LayerHeight+world.World.Level.ElevationOffset(x, y), 0, 1, 1, 0.8, false, false, sprite, screen)
 				}
 			}
 		}
@@ -343,31 +422,39 @@ func (g *game) Draw(screen *ebiten.Image) {
 
 func (g *game) addSystems() {
 	// Simulation systems.
-	gohan.AddSystem(system.NewTickSystem())
-	gohan.AddSystem(system.NewPowerScanSystem())
-	gohan.AddSystem(system.NewPopulateSystem())
-	gohan.AddSystem(system.NewTaxSystem())
+	g.simulation = newSimulationSystem(system.NewSimulationSystems()...)
+	gohan.AddSystem(g.simulation)
 
 	// Input systems.
-	g.movementSystem = system.NewMovementSystem()
-	gohan.AddSystem(system.NewPlayerMoveSystem(world.World.Player, g.movementSystem))
+	g.movementSystem = NewMovementSystem()
+	gohan.AddSystem(NewPlayerMoveSystem(player, g.movementSystem))
 	gohan.AddSystem(g.movementSystem)
 
 	// Render systems.
-	gohan.AddSystem(system.NewCameraSystem())
-	g.renderSystem = system.NewRenderSystem()
+	gohan.AddSystem(NewCameraSystem())
+	g.renderSystem = NewRenderSystem()
 	gohan.AddSystem(g.renderSystem)
-	gohan.AddSystem(system.NewRenderHudSystem())
-	gohan.AddSystem(system.NewRenderDebugTextSystem(world.World.Player))
-	gohan.AddSystem(system.NewProfileSystem(world.World.Player))
+	gohan.AddSystem(NewRenderHudSystem())
+	gohan.AddSystem(NewRenderDebugTextSystem(player))
+	gohan.AddSystem(NewProfileSystem(player))
+
+	// Persistence systems.
+	gohan.AddSystem(newSimulationSystem(system.NewAutosaveSystem()))
 }
 
 func (g *game) loadAssets() error {
-	asset.ImgWhiteSquare.Fill(color.White)
-	asset.LoadSounds(g.audioContext)
+	imgWhiteSquare.Fill(color.White)
+	loadSounds(g.audioContext)
 	return nil
 }
 
+// Exit saves the city and exits the game. It is safe to call from any goroutine.
 func (g *game) Exit() {
+	g.Lock()
+	g.exit()
+}
+
+func (g *game) exit() {
+	world.EndSession()
 	os.Exit(0)
 }
@@ -0,0 +1,532 @@
+package game
+
+import (
+	"image"
+
+	"code.rocketnine.space/tslocum/citylimits/component"
+	"code.rocketnine.space/tslocum/citylimits/world"
+	"code.rocketnine.space/tslocum/gohan"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+type playerMoveSystem struct {
+	Position *component.Position
+	Velocity *component.Velocity
+	Weapon   *component.Weapon
+
+	player       gohan.Entity
+	movement     *MovementSystem
+	lastWalkDirL bool
+
+	rewindTicks    int
+	nextRewindTick int
+
+	scrollDragX, scrollDragY         int
+	scrollCamStartX, scrollCamStartY float64
+
+	lastCommand  world.Command
+	pendingBuild *world.Command // Queued build of a single-use structure
+}
+
+func NewPlayerMoveSystem(player gohan.Entity, m *MovementSystem) *playerMoveSystem {
+	return &playerMoveSystem{
+		player:      player,
+		movement:    m,
+		scrollDragX: -1,
+		scrollDragY: -1,
+	}
+}
+func (s *playerMoveSystem) Update(e gohan.Entity) error {
+	if world.World.RecoveryFile != "" {
+		if inpututil.IsKeyJustPressed(ebiten.KeyY) || inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+			world.Recover()
+		} else if inpututil.IsKeyJustPressed(ebiten.KeyN) || inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+			world.DismissRecovery()
+		}
+		return nil
+	}
+
+	if ebiten.IsKeyPressed(ebiten.KeyEscape) && !world.World.DisableEsc {
+		world.World.ExitGame = true
+		return nil
+	}
+
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyV) {
+		v := 1
+		if ebiten.IsKeyPressed(ebiten.KeyShift) {
+			v = 2
+		}
+		if world.World.Debug == v {
+			world.World.Debug = 0
+		} else {
+			world.World.Debug = v
+		}
+		return nil
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyN) {
+		world.World.NoClip = !world.World.NoClip
+		return nil
+	}
+
+	if !world.World.GameStarted {
+		if ebiten.IsKeyPressed(ebiten.KeyEnter) || ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+			world.StartGame()
+		}
+		return nil
+	}
+
+	// Single-use structures are deselected once they have been built.
+	if s.pendingBuild != nil {
+		if applied, err := s.pendingBuild.Result(); applied {
+			if err == nil && world.World.HoverStructure == s.pendingBuild.StructureType {
+				world.SetHoverStructure(0)
+				world.World.Level.ClearHoverSprites()
+				world.World.BuildDragX, world.World.BuildDragY = -1, -1
+				world.World.LastBuildX, world.World.LastBuildY = -1, -1
+			}
+			s.pendingBuild = nil
+		}
+	}
+
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyS) {
+		world.QuickSave()
+		return nil
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyL) {
+		world.QuickLoad()
+		return nil
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyE) {
+		world.QuickExport()
+		return nil
+	}
+
+	if world.World.Replaying {
+		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+			world.ToggleReplayPaused()
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyTab) {
+			world.CycleReplaySpeed()
+		}
+	}
+
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && (inpututil.IsKeyJustPressed(ebiten.KeyY) || (ebiten.IsKeyPressed(ebiten.KeyShift) && inpututil.IsKeyJustPressed(ebiten.KeyZ))) {
+		world.QueueCommand(&world.Command{Type: world.CommandRedo})
+		return nil
+	}
+	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyZ) {
+		world.QueueCommand(&world.Command{Type: world.CommandUndo})
+		return nil
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		world.World.MuteMusic = !world.World.MuteMusic
+		if world.World.MuteMusic {
+			soundMusic1.Pause()
+			soundMusic2.Pause()
+			soundMusic3.Pause()
+		} else {
+			resumeSong()
+		}
+	}
+
+	if inpututil.IsKeyJustPressed(ebiten.KeyN) {
+		world.World.MuteMusic = false
+		playNextSong()
+	}
+
+	if world.World.GameOver {
+		if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+			world.World.ResetGame = true
+		}
+		return nil
+	}
+
+	// Update target zoom level.
+	var scrollY float64
+	if ebiten.IsKeyPressed(ebiten.KeyC) || ebiten.IsKeyPressed(ebiten.KeyPageDown) {
+		scrollY = -0.25
+	} else if ebiten.IsKeyPressed(ebiten.KeyE) || ebiten.IsKeyPressed(ebiten.KeyPageUp) {
+		scrollY = .25
+	} else {
+		_, scrollY = ebiten.Wheel()
+		if scrollY < -1 {
+			scrollY = -1
+		} else if scrollY > 1 {
+			scrollY = 1
+		}
+	}
+	world.World.CamScaleTarget += scrollY * (world.World.CamScaleTarget / 7)
+	if world.World.CamScaleTarget < world.CameraMinZoom {
+		world.World.CamScaleTarget = world.CameraMinZoom
+	} else if world.World.CamScaleTarget > world.CameraMaxZoom {
+		world.World.CamScaleTarget = world.CameraMaxZoom
+	}
+
+	// Smooth zoom transition.
+	div := 10.0
+	if world.World.CamScaleTarget > world.World.CamScale {
+		world.World.CamScale += (world.World.CamScaleTarget - world.World.CamScale) / div
+	} else if world.World.CamScaleTarget < world.World.CamScale {
+		world.World.CamScale -= (world.World.CamScale - world.World.CamScaleTarget) / div
+	}
+
+	pressLeft := ebiten.IsKeyPressed(ebiten.KeyLeft) || ebiten.IsKeyPressed(ebiten.KeyA)
+	pressRight := ebiten.IsKeyPressed(ebiten.KeyRight) || ebiten.IsKeyPressed(ebiten.KeyD)
+	pressUp := ebiten.IsKeyPressed(ebiten.KeyUp) || ebiten.IsKeyPressed(ebiten.KeyW)
+	pressDown := ebiten.IsKeyPressed(ebiten.KeyDown) || ebiten.IsKeyPressed(ebiten.KeyS)
+
+	const camSpeed = 10
+	if (pressLeft && !pressRight) ||
+		(pressRight && !pressLeft) {
+		if pressLeft {
+			world.World.CamX -= camSpeed
+		} else {
+			world.World.CamX += camSpeed
+		}
+	}
+
+	if (pressUp && !pressDown) ||
+		(pressDown && !pressUp) {
+		if pressUp {
+			world.World.CamY -= camSpeed
+		} else {
+			world.World.CamY += camSpeed
+		}
+	}
+
+	x, y := ebiten.CursorPosition()
+	if !world.World.GotCursorPosition {
+		if x != 0 || y != 0 {
+			world.World.GotCursorPosition = true
+		} else {
+			return nil
+		}
+	}
+	if ebiten.IsMouseButtonPressed(ebiten.MouseButtonMiddle) {
+		if s.scrollDragX == -1 && s.scrollDragY == -1 {
+			// TODO Disabled due to possible ebiten bug.
+			//ebiten.SetCursorMode(ebiten.CursorModeCaptured)
+
+			s.scrollDragX, s.scrollDragY = x, y
+			s.scrollCamStartX, s.scrollCamStartY = world.World.CamX, world.World.CamY
+		} else {
+			dx, dy := float64(x-s.scrollDragX)/world.World.CamScale, float64(y-s.scrollDragY)/world.World.CamScale
+			world.World.CamX, world.World.CamY = s.scrollCamStartX-dx, s.scrollCamStartY-dy
+		}
+	} else {
+		if s.scrollDragX != -1 && s.scrollDragY != -1 {
+			s.scrollDragX, s.scrollDragY = -1, -1
+			//ebiten.SetCursorMode(ebiten.CursorModeVisible)
+		}
+
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonRight) {
+			vX, vY := world.World.ScreenW/2-x, world.World.ScreenH/2-y
+			dx, dy := float64(vX)/world.World.CamScale, float64(vY)/world.World.CamScale
+			world.World.CamX, world.World.CamY = world.World.CamX-dx, world.World.CamY-dy
+		}
+	}
+	// Clamp viewport.
+	levelW, levelH := float64(world.World.Level.Width()), float64(world.World.Level.Height())
+	minCamX := -levelH * world.TileSize / 2
+	maxCamX := levelW * world.TileSize / 2
+	maxCamY := (levelW + levelH) * world.TileSize / 4
+	if world.World.CamX < minCamX {
+		world.World.CamX = minCamX
+	} else if world.World.CamX > maxCamX {
+		world.World.CamX = maxCamX
+	}
+	if world.World.CamY < 0 {
+		world.World.CamY = 0
+	} else if world.World.CamY > maxCamY {
+		world.World.CamY = maxCamY
+	}
+
+	if x < world.SidebarWidth {
+		world.World.Level.ClearHoverSprites()
+
+		world.World.HoverX, world.World.HoverY = 0, 0
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			button := hudButtonAt(x, y)
+			if button != nil {
+				if button.StructureType != 0 {
+					if button.StructureType == world.StructureToggleHelp {
+						if world.World.HelpPage != -1 {
+							world.SetHelpPage(-1)
+						} else {
+							world.SetHelpPage(0)
+						}
+					} else if button.StructureType == world.StructureToggleTransparentStructures {
+						var transparent float64
+						if !world.World.TransparentStructures {
+							transparent = 1
+						}
+						world.QueueCommand(&world.Command{
+							Type:  world.CommandSetTransparentStructures,
+							Value: transparent,
+						})
+					} else {
+						if world.World.HoverStructure == button.StructureType {
+							world.SetHoverStructure(0) // Deselect.
+						} else {
+							world.SetHoverStructure(button.StructureType)
+						}
+					}
+					soundSelect.Rewind()
+					soundSelect.Play()
+				}
+			} else if world.AltButtonAt(x, y) == 0 {
+				world.World.ShowRCIWindow = !world.World.ShowRCIWindow
+				world.World.HUDUpdated = true
+
+				soundSelect.Rewind()
+				soundSelect.Play()
+			} else if world.AltButtonAt(x, y) == 1 {
+				// Cycle between sharing power fairly and supplying each zone
+				// type first.
+				var priority int
+				switch world.World.PowerPriority {
+				case 0:
+					priority = world.StructureResidentialZone
+				case world.StructureResidentialZone:
+					priority = world.StructureCommercialZone
+				case world.StructureCommercialZone:
+					priority = world.StructureIndustrialZone
+				}
+				world.QueueCommand(&world.Command{
+					Type:          world.CommandSetPowerPriority,
+					StructureType: priority,
+				})
+
+				soundSelect.Rewind()
+				soundSelect.Play()
+			}
+		}
+		return nil
+	}
+
+	if handleRCIWindow(x, y) {
+		return nil
+	}
+
+	if x >= world.World.ScreenW-helpW && y >= world.World.ScreenH-helpH {
+		if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) {
+			const (
+				helpPrev = iota
+				helpClose
+				helpNext
+			)
+
+			helpButton := world.HelpButtonAt(x-(world.World.ScreenW-helpW), y-(world.World.ScreenH-helpH))
+			var updated bool
+			switch helpButton {
+			case helpPrev:
+				if world.World.HelpPage > 0 {
+					world.World.HelpPage--
+					updated = true
+				}
+			case helpClose:
+				world.World.HelpPage = -1
+				updated = true
+			case helpNext:
+				if world.World.HelpPage < len(world.HelpText)-1 {
+					world.World.HelpPage++
+					updated = true
+				}
+			}
+			if updated {
+				world.World.HelpUpdated = true
+				world.World.HUDUpdated = true
+
+				soundSelect.Rewind()
+				soundSelect.Play()
+			}
+		}
+		return nil
+	}
+
+	if world.World.HoverStructure != 0 {
+		tileX, tileY := world.ScreenToCartesian(x, y)
+		if tileX >= 0 && tileY >= 0 && tileX < float64(world.World.Level.Width()) && tileY < float64(world.World.Level.Height()) {
+			multiUseStructure := world.IsMultiUseStructure(world.World.HoverStructure)
+			dragStarted := world.World.BuildDragX != -1 || world.World.BuildDragY != -1
+			if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) || (multiUseStructure && ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft)) || (multiUseStructure && dragStarted) {
+				if !dragStarted && world.World.Funds >= world.BuildCost(world.World.HoverStructure, int(tileX), int(tileY)) {
+					world.World.BuildDragX, world.World.BuildDragY = int(tileX), int(tileY)
+
+					if world.World.HoverStructure == world.StructureBulldozer {
+						soundBulldoze.Play()
+					}
+				}
+
+				if world.World.HoverStructure == world.StructureRoad || world.IsPowerLine(world.World.HoverStructure) {
+					tiles := world.RoadTiles(world.World.BuildDragX, world.World.BuildDragY, int(tileX), int(tileY))
+
+					if dragStarted && !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+						commandType, structureType := world.CommandBuildRoad, 0
+						if world.IsPowerLine(world.World.HoverStructure) {
+							commandType, structureType = world.CommandBuildPowerLine, world.World.HoverStructure
+						}
+						world.World.Level.ClearHoverSprites()
+						world.QueueCommand(&world.Command{
+							Type:          commandType,
+							StructureType: structureType,
+							X:             world.World.BuildDragX,
+							Y:             world.World.BuildDragY,
+							ToX:           int(tileX),
+							ToY:           int(tileY),
+						})
+
+						world.World.BuildDragX, world.World.BuildDragY = -1, -1
+						dragStarted = false
+					} else {
+						// TODO draw hover sprites
+						// TODO move below into shared func
+						world.World.Level.ClearHoverSprites()
+						world.BuildStructure(world.World.HoverStructure, true, int(tileX), int(tileY), false)
+						var cost int
+						for _, tile := range tiles {
+							world.BuildStructure(world.World.HoverStructure, true, tile[0], tile[1], false)
+							cost += world.BuildCost(world.World.HoverStructure, tile[0], tile[1])
+						}
+						world.World.HoverValid = cost <= world.World.Funds
+					}
+					return nil
+				} else if dragStarted && !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+					world.World.BuildDragX, world.World.BuildDragY = -1, -1
+					soundBulldoze.Pause()
+				}
+
+				cost := world.BuildCost(world.World.HoverStructure, int(tileX), int(tileY))
+				if world.World.Funds < cost {
+					world.ShowMessage("Insufficient funds", 3)
+				} else {
+					world.World.Level.ClearHoverSprites()
+
+					c := world.Command{
+						Type:          world.CommandBuildStructure,
+						StructureType: world.World.HoverStructure,
+						X:             int(tileX),
+						Y:             int(tileY),
+					}
+					if world.World.HoverStructure == world.StructureBulldozer {
+						c = world.Command{
+							Type: world.CommandBulldoze,
+							X:    int(tileX),
+							Y:    int(tileY),
+						}
+					}
+					// Only queue commands for new locations while dragging.
+					if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) || c != s.lastCommand {
+						s.lastCommand = c
+						world.QueueCommand(&c)
+						if c.Type == world.CommandBuildStructure && !world.IsMultiUseStructure(c.StructureType) {
+							s.pendingBuild = &c
+						}
+					}
+
+					world.BuildStructure(world.World.HoverStructure, true, int(tileX), int(tileY), false)
+				}
+			} else {
+				world.World.Level.ClearHoverSprites()
+
+				world.BuildStructure(world.World.HoverStructure, true, int(tileX), int(tileY), false)
+			}
+			world.World.HoverX, world.World.HoverY = int(tileX), int(tileY)
+		}
+	} else {
+		// Inspect the structure under the cursor.
+		var structure *world.Structure
+		tileX, tileY := world.ScreenToCartesian(x, y)
+		if tileX >= 0 && tileY >= 0 {
+			structure = world.StructureAt(int(tileX), int(tileY))
+		}
+		if structure != world.World.SelectedStructure {
+			world.World.SelectedStructure = structure
+			world.World.HUDUpdated = true
+		}
+	}
+
+	return nil
+}
+
+func (s *playerMoveSystem) Draw(_ gohan.Entity, _ *ebiten.Image) error {
+	return gohan.ErrUnregister
+}
+
+// handleRCIWindow handles input in the tax window. It returns whether the
+// cursor is over the window.
+func handleRCIWindow(x, y int) bool {
+	if !world.World.ShowRCIWindow {
+		return false
+	}
+
+	point := image.Point{x, y}
+	if !point.In(world.World.RCIWindowRect) {
+		return false
+	}
+
+	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
+		return true
+	}
+
+	updatedType, updatedRate := 0, 0.0
+	barRectR := image.Rect(world.World.RCIWindowRect.Min.X+381, world.World.RCIWindowRect.Min.Y, world.World.RCIWindowRect.Min.X+575, world.World.RCIWindowRect.Min.Y+50)
+	barRectC := image.Rect(world.World.RCIWindowRect.Min.X+381, world.World.RCIWindowRect.Min.Y+50, world.World.RCIWindowRect.Min.X+575, world.World.RCIWindowRect.Min.Y+100)
+	barRectI := image.Rect(world.World.RCIWindowRect.Min.X+381, world.World.RCIWindowRect.Min.Y+100, world.World.RCIWindowRect.Min.X+575, world.World.RCIWindowRect.Min.Y+150)
+	barRectP := image.Rect(world.World.RCIWindowRect.Min.X+381, world.World.RCIWindowRect.Min.Y+150, world.World.RCIWindowRect.Min.X+575, world.World.RCIWindowRect.Min.Y+200)
+	barRectF := image.Rect(world.World.RCIWindowRect.Min.X+381, world.World.RCIWindowRect.Min.Y+200, world.World.RCIWindowRect.Min.X+575, world.World.RCIWindowRect.Max.Y)
+	var currentRate float64
+	if point.In(barRectR) {
+		updatedType, currentRate = world.StructureResidentialZone, world.World.TaxR
+		updatedRate = float64(x-barRectR.Min.X) / float64(barRectR.Dx())
+	} else if point.In(barRectC) {
+		updatedType, currentRate = world.StructureCommercialZone, world.World.TaxC
+		updatedRate = float64(x-barRectC.Min.X) / float64(barRectC.Dx())
+	} else if point.In(barRectI) {
+		updatedType, currentRate = world.StructureIndustrialZone, world.World.TaxI
+		updatedRate = float64(x-barRectI.Min.X) / float64(barRectI.Dx())
+	} else if point.In(barRectP) {
+		updatedType, currentRate = world.StructurePoliceStation, world.World.PoliceFunding
+		updatedRate = float64(x-barRectP.Min.X) / float64(barRectP.Dx())
+	} else if point.In(barRectF) {
+		updatedType, currentRate = world.StructureFireStation, world.World.FireFunding
+		updatedRate = float64(x-barRectF.Min.X) / float64(barRectF.Dx())
+	}
+	if updatedType == 0 {
+		return true
+	}
+	if updatedRate >= .99 {
+		updatedRate = 1.0
+	} else if updatedRate < 0 {
+		updatedRate = 0
+	}
+	if updatedRate != currentRate {
+		commandType := world.CommandSetTax
+		if world.IsService(updatedType) {
+			commandType = world.CommandSetFunding
+		}
+		world.QueueCommand(&world.Command{
+			Type:          commandType,
+			StructureType: updatedType,
+			Value:         updatedRate,
+		})
+	}
+
+	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) || world.World.Ticks%16 == 0 {
+		playSoundEffect(soundPop1, soundPop2, soundPop3, soundPop4, soundPop5)
+	}
+	return true
+}
+
+func deltaXY(x1, y1, x2, y2 float64) (dx float64, dy float64) {
+	dx, dy = x1-x2, y1-y2
+	if dx < 0 {
+		dx *= -1
+	}
+	if dy < 0 {
+		dy *= -1
+	}
+	return dx, dy
+}
@@ -1,4 +1,4 @@
-package system
+package game
 
 import (
 	"image"
@@ -47,7 +47,7 @@ func (s *MovementSystem) Update(e gohan.Entity) error {
 		return nil
 	}
 
-	if world.World.GameOver && e == world.World.Player {
+	if world.World.GameOver && e == player {
 		return nil
 	}
 
@@ -55,7 +55,7 @@ func (s *MovementSystem) Update(e gohan.Entity) error {
 	velocity := s.Velocity
 
 	vx, vy := velocity.X, velocity.Y
-	if e == world.World.Player && (world.World.NoClip || world.World.Debug != 0) && ebiten.IsKeyPressed(ebiten.KeyShift) {
+	if e == player && (world.World.NoClip || world.World.Debug != 0) && ebiten.IsKeyPressed(ebiten.KeyShift) {
 		vx, vy = vx*2, vy*2
 	}
 
@@ -63,7 +63,7 @@ func (s *MovementSystem) Update(e gohan.Entity) error {
 
 	// Force player to remain within the screen bounds.
 	// TODO same for bullets
-	if e == world.World.Player {
+	if e == player {
 		screenX, screenY := s.levelCoordinatesToScreen(position.X, position.Y)
 		if screenX < 0 {
 			diff := screenX / world.World.CamScale
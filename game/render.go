@@ -1,4 +1,4 @@
-package system
+package game
 
 import (
 	_ "image/png"
@@ -120,7 +120,7 @@ func (s *RenderSystem) renderSprite(x float64, y float64, offsetx float64, offse
 func (s *RenderSystem) Draw(e gohan.Entity, screen *ebiten.Image) error {
 	if !world.World.GameStarted {
 		// TODO
-		if e == world.World.Player {
+		if e == player {
 			screen.Fill(colornames.Purple)
 		}
 		return nil
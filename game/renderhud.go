@@ -1,4 +1,4 @@
-package system
+package game
 
 import (
 	"fmt"
@@ -62,12 +62,33 @@ func (s *RenderHudSystem) Draw(_ gohan.Entity, screen *ebiten.Image) error {
 		s.drawTooltip()
 		s.drawRCIWindow()
 		s.drawHelp()
+		s.drawRecoveryPrompt()
 		world.World.HUDUpdated = false
 	}
 	screen.DrawImage(s.hudImg, nil)
 	return nil
 }
 
+// hudButton is a button in the sidebar.
+type hudButton struct {
+	Sprite                       *ebiten.Image
+	SpriteOffsetX, SpriteOffsetY float64
+	Label                        string
+	StructureType                int
+}
+
+var hudButtons []*hudButton
+
+func hudButtonAt(x, y int) *hudButton {
+	point := image.Point{x, y}
+	for i, rect := range world.World.HUDButtonRects {
+		if point.In(rect) {
+			return hudButtons[i]
+		}
+	}
+	return nil
+}
+
 const columns = 3
 const buttonWidth = world.SidebarWidth / columns
 
@@ -90,9 +111,9 @@ func (s *RenderHudSystem) drawSidebar() {
 
 	const paddingSize = 1
 	const buttonHeight = buttonWidth
-	world.World.HUDButtonRects = make([]image.Rectangle, len(world.HUDButtons))
+	world.World.HUDButtonRects = make([]image.Rectangle, len(hudButtons))
 	var lastButtonY int
-	for i, button := range world.HUDButtons {
+	for i, button := range hudButtons {
 		row := i / columns
 		x, y := (i%columns)*buttonWidth, row*buttonHeight
 		r := image.Rect(x+paddingSize, y+paddingSize, x+buttonWidth-paddingSize, y+buttonHeight-paddingSize)
@@ -278,9 +299,10 @@ func (s *RenderHudSystem) drawPower(x, y int) {
 	colorPowerNormal := color.RGBA{0, 255, 0, 255}
 	colorPowerOut := color.RGBA{255, 0, 0, 255}
 	colorPowerCapacity := color.RGBA{16, 16, 16, 255}
+	colorPowerLost := color.RGBA{255, 140, 0, 255}
 	drawPowerBar := func(demand float64, clr color.RGBA, i int) {
 		barOffsetSize := 7
-		barOffset := -barOffsetSize + (i * barOffsetSize)
+		barOffset := -barOffsetSize - barOffsetSize/2 + (i * barOffsetSize)
 		barWidth := 7
 		barX := rciX + buttonWidth/2 - barWidth/2 + barOffset + 4
 		barY := rciY + (rciSize / 2)
@@ -294,7 +316,7 @@ func (s *RenderHudSystem) drawPower(x, y int) {
 	}
 
 	powerColor := colorPowerNormal
-	if world.World.HavePowerOut || world.World.PowerNeeded > world.World.PowerAvailable {
+	if world.World.HavePowerOut || world.World.HaveBrownout || world.World.PowerNeeded > world.World.PowerAvailable {
 		powerColor = colorPowerOut
 	}
 
@@ -304,6 +326,7 @@ func (s *RenderHudSystem) drawPower(x, y int) {
 	}
 
 	pctUsage, pctCapacity := float64(world.World.PowerNeeded)/float64(max), float64(world.World.PowerAvailable)/float64(max)
+	pctLost := float64(world.World.PowerLost) / float64(max)
 	clamp := func(v float64) float64 {
 		if math.IsNaN(v) {
 			return 0
@@ -318,6 +341,7 @@ func (s *RenderHudSystem) drawPower(x, y int) {
 
 	drawPowerBar(clamp(pctUsage), powerColor, 0)
 	drawPowerBar(clamp(pctCapacity), colorPowerCapacity, 1)
+	drawPowerBar(clamp(pctLost), colorPowerLost, 2)
 
 	// Draw button.
 	const rciButtonPadding = 12
@@ -326,12 +350,17 @@ func (s *RenderHudSystem) drawPower(x, y int) {
 	rciButtonY := rciY + (rciSize / 2) - (rciButtonHeight / 2)
 	rciButtonRect := image.Rect(rciX+rciButtonPadding, rciButtonY, rciX+buttonWidth-rciButtonPadding, rciButtonY+rciButtonHeight)
 
-	s.drawButtonBackground(s.tmpImg, rciButtonRect, false) // TODO
+	// The button is shown pressed while a zone type is supplied first.
+	prioritized := world.World.PowerPriority != 0
+
+	s.drawButtonBackground(s.tmpImg, rciButtonRect, prioritized)
 
 	// Draw label.
 	ebitenutil.DebugPrintAt(s.tmpImg, "POWER", rciX+rciButtonPadding+rciButtonLabelPaddingX, rciButtonY+rciButtonLabelPaddingY)
 
-	s.drawButtonBorder(s.tmpImg, rciButtonRect, false) // TODO
+	s.drawButtonBorder(s.tmpImg, rciButtonRect, prioritized)
+
+	world.World.PowerButtonRect = rciButtonRect
 }
 
 func (s *RenderHudSystem) drawMessages() {
@@ -508,7 +537,7 @@ func (s *RenderHudSystem) drawRCIWindow() {
 
 	const (
 		rciWindowW = 425
-		rciWindowH = 100
+		rciWindowH = 166
 	)
 
 	rciWindowRect := image.Rect(world.World.ScreenW/2-rciWindowW/2, world.World.ScreenH/2-rciWindowH/2, world.World.ScreenW/2+rciWindowW, world.World.ScreenH/2+rciWindowH)
@@ -527,10 +556,14 @@ func (s *RenderHudSystem) drawRCIWindow() {
 Residential %3s%%  - |%s| +
 Commercial  %3s%%  - |%s| +
 Industrial  %3s%%  - |%s| +
+Police      %3s%%  - |%s| +
+Fire        %3s%%  - |%s| +
 `,
 		strconv.Itoa(int(world.World.TaxR*100)), percentBar(world.World.TaxR),
 		strconv.Itoa(int(world.World.TaxC*100)), percentBar(world.World.TaxC),
-		strconv.Itoa(int(world.World.TaxI*100)), percentBar(world.World.TaxI))
+		strconv.Itoa(int(world.World.TaxI*100)), percentBar(world.World.TaxI),
+		strconv.Itoa(int(world.World.PoliceFunding*100)), percentBar(world.World.PoliceFunding),
+		strconv.Itoa(int(world.World.FireFunding*100)), percentBar(world.World.FireFunding))
 
 	s.tmpImg.Clear()
 	ebitenutil.DebugPrint(s.tmpImg, strings.TrimSpace(label))
@@ -547,3 +580,36 @@ Industrial  %3s%%  - |%s| +
 
 	world.World.RCIWindowRect = rciWindowRect
 }
+
+func (s *RenderHudSystem) drawRecoveryPrompt() {
+	if world.World.RecoveryFile == "" {
+		return
+	}
+
+	const (
+		promptW = 480
+		promptH = 110
+	)
+
+	label := `
+The previous session ended
+unexpectedly. Recover the most
+recent autosave? (Y/N)
+`
+
+	promptRect := image.Rect(world.World.ScreenW/2-promptW/2, world.World.ScreenH/2-promptH/2, world.World.ScreenW/2+promptW/2, world.World.ScreenH/2+promptH/2)
+	s.hudImg.SubImage(promptRect).(*ebiten.Image).Fill(s.sidebarColor)
+
+	s.tmpImg.Clear()
+	ebitenutil.DebugPrint(s.tmpImg, strings.TrimSpace(label))
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(2, 2)
+	op.GeoM.Translate(float64(promptRect.Min.X)+8, float64(promptRect.Min.Y)+4)
+	s.hudImg.DrawImage(s.tmpImg, op)
+
+	s.hudImg.SubImage(image.Rect(promptRect.Min.X, promptRect.Min.Y, promptRect.Max.X, promptRect.Min.Y+1)).(*ebiten.Image).Fill(color.Black)
+	s.hudImg.SubImage(image.Rect(promptRect.Min.X, promptRect.Max.Y-1, promptRect.Max.X, promptRect.Max.Y)).(*ebiten.Image).Fill(color.Black)
+	s.hudImg.SubImage(image.Rect(promptRect.Min.X, promptRect.Min.Y, promptRect.Min.X+1, promptRect.Max.Y)).(*ebiten.Image).Fill(color.Black)
+	s.hudImg.SubImage(image.Rect(promptRect.Max.X-1, promptRect.Min.Y, promptRect.Max.X, promptRect.Max.Y)).(*ebiten.Image).Fill(color.Black)
+}
@@ -0,0 +1,37 @@
+package game
+
+import (
+	"code.rocketnine.space/tslocum/citylimits/component"
+	"code.rocketnine.space/tslocum/citylimits/system"
+	"code.rocketnine.space/tslocum/gohan"
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// simulationSystem updates simulation systems as part of the game.
+type simulationSystem struct {
+	Position *component.Position
+	Velocity *component.Velocity
+	Weapon   *component.Weapon
+
+	systems []system.System
+}
+
+func newSimulationSystem(systems ...system.System) *simulationSystem {
+	return &simulationSystem{
+		systems: systems,
+	}
+}
+
+func (s *simulationSystem) Update(_ gohan.Entity) error {
+	for _, system := range s.systems {
+		err := system.Update()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *simulationSystem) Draw(_ gohan.Entity, _ *ebiten.Image) error {
+	return gohan.ErrUnregister
+}
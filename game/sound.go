@@ -0,0 +1,83 @@
+package game
+
+import (
+	"math/rand"
+
+	"code.rocketnine.space/tslocum/citylimits/world"
+	"github.com/hajimehoshi/ebiten/v2/audio"
+)
+
+// playingSong is the index of the song currently playing.
+var playingSong int
+
+// playSoundEffect plays one of the provided sound effects at random.
+func playSoundEffect(sounds ...*audio.Player) {
+	if world.World.MuteSoundEffects || len(sounds) == 0 {
+		return
+	}
+
+	sound := sounds[rand.Intn(len(sounds))]
+	sound.Rewind()
+	sound.Play()
+}
+
+// playSoundEffects plays the sound effects requested by the simulation.
+func playSoundEffects() {
+	for _, sound := range world.World.SoundEffects {
+		switch sound {
+		case world.SoundBuild:
+			playSoundEffect(soundPop2, soundPop3)
+		case world.SoundBulldozeTree:
+			playSoundEffect(soundPop1, soundPop4, soundPop5)
+		case world.SoundDemolish:
+			playSoundEffect(soundExplosion1, soundExplosion2)
+		}
+	}
+	world.World.SoundEffects = nil
+}
+
+// updateMusic plays the next song when no song is playing.
+func updateMusic() {
+	if world.World.Ticks%144 != 0 || world.World.MuteMusic {
+		return
+	}
+	if !soundMusic1.IsPlaying() && !soundMusic2.IsPlaying() && !soundMusic3.IsPlaying() {
+		playNextSong()
+	}
+}
+
+func playNextSong() {
+	const numSongs = 3
+
+	soundMusic1.Pause()
+	soundMusic2.Pause()
+	soundMusic3.Pause()
+
+	playingSong++
+	if playingSong == numSongs {
+		playingSong = 0
+	}
+
+	switch playingSong {
+	case 0:
+		soundMusic1.Rewind()
+		soundMusic1.Play()
+	case 1:
+		soundMusic2.Rewind()
+		soundMusic2.Play()
+	case 2:
+		soundMusic3.Rewind()
+		soundMusic3.Play()
+	}
+}
+
+func resumeSong() {
+	switch playingSong {
+	case 0:
+		soundMusic1.Play()
+	case 1:
+		soundMusic2.Play()
+	case 2:
+		soundMusic3.Play()
+	}
+}
@@ -0,0 +1,80 @@
+package game
+
+import (
+	"code.rocketnine.space/tslocum/citylimits/world"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/lafriks/go-tiled"
+)
+
+var (
+	tilesets   []*ebiten.Image
+	tileImages = make(map[uint32]*ebiten.Image) // Indexed by tile GID
+)
+
+func drawMap(structureType int) *ebiten.Image {
+	img := ebiten.NewImage(128, 128)
+
+	m, err := world.LoadMap(structureType)
+	if err != nil {
+		panic(err)
+	}
+
+	var t *tiled.LayerTile
+	for i, layer := range m.Layers {
+		for y := 0; y < m.Height; y++ {
+			for x := 0; x < m.Width; x++ {
+				t = layer.Tiles[y*m.Width+x]
+				if t == nil || t.Nil {
+					continue // No tile at this position.
+				}
+
+				tileImg := tileImages[t.Tileset.FirstGID+t.ID]
+				if tileImg == nil {
+					continue
+				}
+
+				xi, yi := world.CartesianToIso(float64(x), float64(y))
+
+				scale := 0.9 / float64(m.Width)
+				if m.Width < 2 {
+					scale = 0.6
+				}
+
+				paddingX := 64.0
+				op := &ebiten.DrawImageOptions{}
+				op.GeoM.Translate(xi+(paddingX*(float64(m.Width)-1)), (yi+float64(i*-40))+92)
+				op.GeoM.Scale(scale, scale)
+				img.DrawImage(tileImg, op)
+			}
+		}
+	}
+
+	return img
+}
+
+func loadTileset() error {
+	m, err := world.LoadMap(world.StructureResidentialLow)
+	if err != nil {
+		return err
+	}
+
+	// Load tileset.
+
+	if len(tilesets) != 0 {
+		return nil // Already loaded.
+	}
+
+	tileset, img, err := world.LoadTilesetImage(m)
+	if err != nil {
+		panic(err)
+	}
+	tilesets = append(tilesets, ebiten.NewImageFromImage(img))
+
+	// Load tiles.
+
+	for i := uint32(0); i < uint32(tileset.TileCount); i++ {
+		rect := tileset.GetTileRect(i)
+		tileImages[i+tileset.FirstGID] = tilesets[0].SubImage(rect).(*ebiten.Image)
+	}
+	return nil
+}
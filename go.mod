@@ -4,7 +4,6 @@ go 1.17
 
 require (
 	code.rocketnine.space/tslocum/gohan v1.0.0
-	github.com/beefsack/go-astar v0.0.0-20200827232313-4ecf9e304482
 	github.com/hajimehoshi/ebiten/v2 v2.3.6
 	github.com/lafriks/go-tiled v0.7.0
 	golang.org/x/image v0.0.0-20220617043117-41969df76e82
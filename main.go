@@ -13,6 +13,21 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		var run func(args []string) error
+		switch os.Args[1] {
+		case "export":
+			run = runExport
+		}
+		if run != nil {
+			err := run(os.Args[2:])
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
 	ebiten.SetWindowTitle("City Limits")
 	ebiten.SetWindowResizable(true)
 	ebiten.SetWindowSize(640, 480)
@@ -28,6 +43,13 @@ func main() {
 
 	parseFlags()
 
+	// Recovery is not offered when a city or replay is loaded at startup.
+	if world.World.LoadFile == "" && world.World.ReplayFile == "" {
+		world.World.RecoveryFile = world.BeginSession()
+	} else {
+		world.BeginSession()
+	}
+
 	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc,
 		syscall.SIGINT,
@@ -0,0 +1,31 @@
+package system
+
+import (
+	"fmt"
+
+	"code.rocketnine.space/tslocum/citylimits/world"
+)
+
+type AutosaveSystem struct{}
+
+func NewAutosaveSystem() *AutosaveSystem {
+	s := &AutosaveSystem{}
+
+	return s
+}
+
+func (s *AutosaveSystem) Update() error {
+	if world.World.Paused || world.World.Replaying || world.World.AutosaveMonths <= 0 || world.World.Ticks == 0 {
+		return nil
+	}
+
+	if world.World.Ticks%(world.MonthTicks*world.World.AutosaveMonths) != 0 {
+		return nil
+	}
+
+	err := world.Autosave()
+	if err != nil {
+		world.ShowMessage(fmt.Sprintf("Failed to autosave city: %s", err), 5)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package system
+
+import (
+	"code.rocketnine.space/tslocum/citylimits/world"
+)
+
+// CommandSystem applies queued commands. It must be added before all other
+// simulation systems so that commands are applied before the world advances.
+type CommandSystem struct{}
+
+func NewCommandSystem() *CommandSystem {
+	s := &CommandSystem{}
+
+	return s
+}
+
+func (s *CommandSystem) Update() error {
+	world.ApplyCommands()
+	world.UpdateReplay()
+	return nil
+}
@@ -0,0 +1,58 @@
+package system
+
+import (
+	"code.rocketnine.space/tslocum/citylimits/world"
+)
+
+// CrimeSystem updates the crime rate of each zone and pays for police
+// stations each month.
+type CrimeSystem struct{}
+
+func NewCrimeSystem() *CrimeSystem {
+	s := &CrimeSystem{}
+
+	return s
+}
+
+func (s *CrimeSystem) Update() error {
+	if world.World.Paused {
+		return nil
+	}
+
+	if world.World.Ticks%world.MonthTicks != 0 {
+		return nil
+	}
+
+	// Crime is generated by dense and unemployed populations.
+	const (
+		densityCrime      = 50
+		unemploymentCrime = 30
+	)
+	unemployment := world.Unemployment()
+	for _, zone := range world.World.Zones {
+		if zone.Population == 0 {
+			zone.Crime = 0
+			continue
+		}
+
+		density := float64(zone.Population) / world.MaxZonePopulation
+		crime := density*densityCrime + unemployment*unemploymentCrime
+		crime *= 1 - world.PoliceCoverage(zone.X, zone.Y)
+		zone.Crime = int(crime)
+		if zone.Crime > world.MaxCrime {
+			zone.Crime = world.MaxCrime
+		}
+	}
+
+	var stations int
+	for _, structure := range world.World.Structures {
+		if structure.Type == world.StructurePoliceStation {
+			stations++
+		}
+	}
+	if stations > 0 {
+		world.World.Funds -= int(float64(stations*world.PoliceStationUpkeep) * world.World.PoliceFunding)
+		world.World.HUDUpdated = true
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+
+	"code.rocketnine.space/tslocum/citylimits/world"
+)
+
+// FireSystem starts, spreads and extinguishes fires and pays for fire
+// stations each month.
+type FireSystem struct{}
+
+func NewFireSystem() *FireSystem {
+	s := &FireSystem{}
+
+	return s
+}
+
+func (s *FireSystem) Update() error {
+	if world.World.Paused {
+		return nil
+	}
+
+	const fireTicks = world.MonthTicks / world.FireDuration
+	if world.World.Ticks%fireTicks == 0 {
+		s.burn()
+	}
+
+	if world.World.Ticks%world.MonthTicks == 0 {
+		s.ignite()
+
+		var stations int
+		for _, structure := range world.World.Structures {
+			if structure.Type == world.StructureFireStation {
+				stations++
+			}
+		}
+		if stations > 0 {
+			world.World.Funds -= int(float64(stations*world.FireStationUpkeep) * world.World.FireFunding)
+			world.World.HUDUpdated = true
+		}
+	}
+	return nil
+}
+
+// ignite starts fires in industrial and densely populated zones.
+func (s *FireSystem) ignite() {
+	// Monthly chance of fire per resident.
+	const (
+		industrialRisk = 0.002
+		denseRisk      = 0.001
+		denseZone      = 8
+	)
+	for _, zone := range world.World.Zones {
+		var risk float64
+		if zone.Type == world.StructureIndustrialZone {
+			risk = industrialRisk * float64(zone.Population)
+		} else if zone.Population >= denseZone {
+			risk = denseRisk * float64(zone.Population)
+		}
+		if risk == 0 {
+			continue
+		}
+		risk *= 1 - world.FireCoverage(zone.X, zone.Y)
+		if world.World.Rand.Float64() >= risk {
+			continue
+		}
+
+		structure := world.StructureAt(zone.X, zone.Y)
+		if structure != nil && world.IgniteStructure(structure) {
+			world.ShowMessage(fmt.Sprintf("Fire! A fire broke out in %s", structureName(structure)), 5)
+		}
+	}
+}
+
+// burn spreads fires to neighboring structures. Fires within reach of a fire
+// station may be extinguished. Structures which burn for too long are
+// destroyed.
+func (s *FireSystem) burn() {
+	const (
+		spreadChance     = 0.2
+		extinguishChance = 0.8
+	)
+	for _, fire := range append([]*world.Fire(nil), world.World.Fires...) {
+		structure := world.StructureAt(fire.X, fire.Y)
+		if structure == nil {
+			world.ExtinguishFire(fire)
+			continue
+		}
+
+		if world.World.Rand.Float64() < extinguishChance*world.FireCoverage(fire.X, fire.Y) {
+			world.ExtinguishFire(fire)
+			world.ShowMessage(fmt.Sprintf("Firefighters put out a fire in %s", structureName(structure)), 3)
+			continue
+		}
+
+		for _, neighbor := range world.NeighborsOf(structure) {
+			if world.World.Rand.Float64() < spreadChance*(1-world.FireCoverage(neighbor.X, neighbor.Y)) {
+				world.IgniteStructure(neighbor)
+			}
+		}
+
+		fire.Age++
+		if fire.Age >= world.FireDuration {
+			world.BurnDown(structure)
+			world.ShowMessage(fmt.Sprintf("A fire destroyed %s", structureName(structure)), 5)
+		}
+	}
+}
+
+// structureName returns the name of a structure for use in messages.
+func structureName(s *world.Structure) string {
+	name := strings.ToLower(world.StructureTooltips[s.Type])
+	if name == "" {
+		return "a building"
+	}
+	switch name[0] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return "an " + name
+	default:
+		return "a " + name
+	}
+}
@@ -0,0 +1,38 @@
+package system
+
+import (
+	"code.rocketnine.space/tslocum/citylimits/world"
+)
+
+// PollutionSystem spreads pollution each month. Residents of heavily
+// polluted zones become ill and move away.
+type PollutionSystem struct{}
+
+func NewPollutionSystem() *PollutionSystem {
+	s := &PollutionSystem{}
+
+	return s
+}
+
+func (s *PollutionSystem) Update() error {
+	if world.World.Paused {
+		return nil
+	}
+
+	if world.World.Ticks%world.MonthTicks != 0 {
+		return nil
+	}
+
+	world.UpdatePollution()
+
+	for _, zone := range world.World.Zones {
+		if zone.Type != world.StructureResidentialZone || zone.Population == 0 {
+			continue
+		}
+		if world.ZonePollution(zone) >= world.UnhealthyPollution {
+			zone.Population--
+		}
+	}
+	world.World.HUDUpdated = true
+	return nil
+}
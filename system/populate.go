@@ -1,17 +1,10 @@
 package system
 
 import (
-	"code.rocketnine.space/tslocum/citylimits/component"
 	"code.rocketnine.space/tslocum/citylimits/world"
-	"code.rocketnine.space/tslocum/gohan"
-	"github.com/hajimehoshi/ebiten/v2"
 )
 
-type PopulateSystem struct {
-	Position *component.Position
-	Velocity *component.Velocity
-	Weapon   *component.Weapon
-}
+type PopulateSystem struct{}
 
 func NewPopulateSystem() *PopulateSystem {
 	s := &PopulateSystem{}
@@ -19,7 +12,7 @@ func NewPopulateSystem() *PopulateSystem {
 	return s
 }
 
-func (s *PopulateSystem) Update(_ gohan.Entity) error {
+func (s *PopulateSystem) Update() error {
 	if world.World.Paused {
 		return nil
 	}
@@ -74,7 +67,6 @@ func (s *PopulateSystem) Update(_ gohan.Entity) error {
 		}
 	}
 
-	const maxPopulation = 10
 	popR, popC, popI := world.Population()
 	targetR, targetC, targetI := world.TargetPopulation()
 	for _, zone := range world.World.Zones {
@@ -98,6 +90,7 @@ func (s *PopulateSystem) Update(_ gohan.Entity) error {
 				offset = -1
 			}
 		}
+		// Zones in a brownout grow at a rate proportional to the power supplied.
 		if offset == -1 && zone.Population > 0 {
 			zone.Population--
 			if zone.Type == world.StructureResidentialZone {
@@ -107,7 +100,7 @@ func (s *PopulateSystem) Update(_ gohan.Entity) error {
 			} else { // Industrial
 				popI--
 			}
-		} else if offset == 1 && zone.Population < maxPopulation && zone.Powered {
+		} else if offset == 1 && zone.Population < world.MaxZonePopulation && zone.Powered && (zone.PowerRatio >= 1 || world.World.Rand.Float64() < zone.PowerRatio) && (zone.Type != world.StructureResidentialZone || (zone.Crime < world.HighCrime && world.ZonePollution(zone) < world.HighPollution)) {
 			zone.Population++
 			if zone.Type == world.StructureResidentialZone {
 				popR++
@@ -118,9 +111,13 @@ func (s *PopulateSystem) Update(_ gohan.Entity) error {
 			}
 		}
 		newType := buildStructureType(zone.Type, zone.Population)
+		structure := world.StructureAt(zone.X, zone.Y)
+		if structure == nil {
+			continue
+		}
 		// TODO only bulldoze when changed
-		for offsetX := 0; offsetX < 2; offsetX++ {
-			for offsetY := 0; offsetY < 2; offsetY++ {
+		for offsetX := 0; offsetX < structure.Width; offsetX++ {
+			for offsetY := 0; offsetY < structure.Height; offsetY++ {
 				world.BuildStructure(world.StructureBulldozer, false, zone.X-offsetX, zone.Y-offsetY, true)
 			}
 		}
@@ -131,7 +128,3 @@ func (s *PopulateSystem) Update(_ gohan.Entity) error {
 	// for zone in zones
 	return nil
 }
-
-func (s *PopulateSystem) Draw(_ gohan.Entity, screen *ebiten.Image) error {
-	return gohan.ErrUnregister
-}
@@ -1,19 +1,12 @@
 package system
 
 import (
-	"github.com/beefsack/go-astar"
+	"math"
 
-	"code.rocketnine.space/tslocum/citylimits/component"
 	"code.rocketnine.space/tslocum/citylimits/world"
-	"code.rocketnine.space/tslocum/gohan"
-	"github.com/hajimehoshi/ebiten/v2"
 )
 
-type PowerScanSystem struct {
-	Position *component.Position
-	Velocity *component.Velocity
-	Weapon   *component.Weapon
-}
+type PowerScanSystem struct{}
 
 func NewPowerScanSystem() *PowerScanSystem {
 	s := &PowerScanSystem{}
@@ -21,140 +14,156 @@ func NewPowerScanSystem() *PowerScanSystem {
 	return s
 }
 
-func (s *PowerScanSystem) Update(_ gohan.Entity) error {
+func (s *PowerScanSystem) Update() error {
 	if world.World.Paused {
 		return nil
 	}
 
-	const scanTicks = 144 * 2
-	if world.World.Ticks%scanTicks != 0 {
-		return nil
-	}
-
+	// Power is distributed again as soon as a road, power line, power
+	// plant or zone is built or bulldozed.
 	if !world.World.PowerUpdated {
 		return nil
 	}
+	world.World.PowerUpdated = false
 
 	var totalPowerAvailable int
 
-	powerRemaining := make([]int, len(world.World.PowerPlants))
+	powerRemaining := make([]float64, len(world.World.PowerPlants))
 	for i, plant := range world.World.PowerPlants {
-		powerRemaining[i] = world.PowerPlantCapacities[plant.Type]
-		totalPowerAvailable += world.PowerPlantCapacities[plant.Type]
+		powerRemaining[i] = float64(plant.Capacity())
+		totalPowerAvailable += plant.Capacity()
 	}
 
-	const (
-		plantSize = 5
-	)
-	powerSourceTiles := make([][]*world.PowerMapTile, len(world.World.PowerPlants))
-	for i, plant := range world.World.PowerPlants {
-		for y := 0; y < plantSize; y++ {
-			t := world.World.Power.GetTile(plant.X+1, plant.Y-y)
-			if t != nil {
-				powerSourceTiles[i] = append(powerSourceTiles[i], t)
-			}
+	world.UpdatePowerNetworks()
 
-			t = world.World.Power.GetTile(plant.X-plantSize, plant.Y-y)
-			if t != nil {
-				powerSourceTiles[i] = append(powerSourceTiles[i], t)
-			}
+	// Zones are supplied via each power network they border.
+	networkZones := make(map[int][]int)
+	zoneTiles := make([][]*world.PowerMapTile, len(world.World.Zones))
+	for i, zone := range world.World.Zones {
+		structure := world.StructureAt(zone.X, zone.Y)
+		if structure == nil {
+			continue
 		}
-		for x := 0; x < plantSize; x++ {
-			t := world.World.Power.GetTile(plant.X-x, plant.Y+1)
-			if t != nil {
-				powerSourceTiles[i] = append(powerSourceTiles[i], t)
-			}
+		for _, network := range structure.PowerNetworks() {
+			networkZones[network] = append(networkZones[network], i)
+		}
+		zoneTiles[i] = structure.PowerTiles()
+	}
 
-			t = world.World.Power.GetTile(plant.X, plant.Y-plantSize)
-			if t != nil {
-				powerSourceTiles[i] = append(powerSourceTiles[i], t)
+	// Calculate the transmission loss from each power plant to each zone it
+	// reaches.
+	type supply struct {
+		plant int
+		loss  int
+	}
+	zoneSupplies := make([][]supply, len(world.World.Zones))
+	for i, plant := range world.World.PowerPlants {
+		structure := world.StructureAt(plant.X, plant.Y)
+		if structure == nil {
+			continue
+		}
+		world.ScanPowerLosses(structure)
+		for _, network := range structure.PowerNetworks() {
+			for _, j := range networkZones[network] {
+				// Zones bordering more than one of the power plant's networks
+				// are supplied by the power plant once.
+				if n := len(zoneSupplies[j]); n > 0 && zoneSupplies[j][n-1].plant == i {
+					continue
+				}
+				loss := world.TransmissionLoss(zoneTiles[j])
+				if loss == -1 {
+					continue
+				}
+				zoneSupplies[j] = append(zoneSupplies[j], supply{i, loss})
 			}
 		}
 	}
 
 	var totalPowerRequired int
-
-	var havePowerOut bool
+	var totalPowerLost float64
 
 	world.ResetPowerOuts()
 
-	// TODO use a consistent procedure to check each building that needs power
-	// as connected via road to a power plant, and power-out buildings without enough power
-	// "citizens report brown-outs"
-	for _, zone := range world.World.Zones {
-		// TODO lock, set powered status on build immediately
-
-		powerRequired := world.ZonePowerRequirement[zone.Type]
-		_ = powerRequired
-
-		const zoneSize = 2
-		var powerDestinationTiles []*world.PowerMapTile
-		for y := 0; y < zoneSize; y++ {
-			t := world.World.Power.GetTile(zone.X+1, zone.Y-y)
-			if t != nil {
-				powerDestinationTiles = append(powerDestinationTiles, t)
-			}
+	// Zones of the priority type are supplied first. The remaining zones are
+	// supplied with the power which remains.
+	zoneGroups := [][]int{nil, nil}
+	for i, zone := range world.World.Zones {
+		group := 1
+		if zone.Type == world.World.PowerPriority {
+			group = 0
+		}
+		zoneGroups[group] = append(zoneGroups[group], i)
+	}
 
-			t = world.World.Power.GetTile(zone.X-zoneSize, zone.Y-y)
-			if t != nil {
-				powerDestinationTiles = append(powerDestinationTiles, t)
+	for _, group := range zoneGroups {
+		// Each zone is supplied by the power plant with the least transmission
+		// loss which has enough power remaining, or by the power plant with
+		// the least transmission loss which has any power remaining when no
+		// power plant has enough. Power lost in transmission is drawn from the
+		// power plant in addition to the power required.
+		suppliers := make([]supply, len(world.World.Zones))
+		powerDemand := make([]float64, len(world.World.PowerPlants))
+		for _, i := range group {
+			powerRequired := world.ZonePowerRequirement[world.World.Zones[i].Type]
+
+			supplier, partialSupplier := supply{plant: -1}, supply{plant: -1}
+			better := func(s supply, than supply) bool {
+				return than.plant == -1 || s.loss < than.loss || (s.loss == than.loss && s.plant < than.plant)
 			}
-		}
-		for x := 0; x < zoneSize; x++ {
-			t := world.World.Power.GetTile(zone.X-x, zone.Y+1)
-			if t != nil {
-				powerDestinationTiles = append(powerDestinationTiles, t)
+			for _, s := range zoneSupplies[i] {
+				remaining := powerRemaining[s.plant] - powerDemand[s.plant]
+				if remaining >= world.PowerDrawn(powerRequired, s.loss) && better(s, supplier) {
+					supplier = s
+				} else if powerRemaining[s.plant] > 0 && better(s, partialSupplier) {
+					partialSupplier = s
+				}
 			}
-
-			t = world.World.Power.GetTile(zone.X, zone.Y-zoneSize)
-			if t != nil {
-				powerDestinationTiles = append(powerDestinationTiles, t)
+			if supplier.plant == -1 {
+				supplier = partialSupplier
+			}
+			suppliers[i] = supplier
+			if supplier.plant != -1 {
+				powerDemand[supplier.plant] += world.PowerDrawn(powerRequired, supplier.loss)
 			}
 		}
 
-		var powered bool
-	FINDPOWERPATH:
-		for j := range powerRemaining {
-			if powerRemaining[j] < powerRequired {
-				continue
+		// Power plants which are short of power supply each zone with the same
+		// fraction of the power it requires.
+		powerRatios := make([]float64, len(world.World.PowerPlants))
+		for i, demand := range powerDemand {
+			powerRatios[i] = 1
+			if demand > powerRemaining[i]+1e-9 { // Allow for rounding errors.
+				powerRatios[i] = powerRemaining[i] / demand
+				demand = powerRemaining[i]
 			}
+			powerRemaining[i] -= demand
+		}
 
-			for _, powerSource := range powerSourceTiles[j] {
-				from := world.World.Power.GetTile(powerSource.X, powerSource.Y)
-
-				for _, to := range powerDestinationTiles {
-					if to == nil {
-						continue
-					}
+		for _, i := range group {
+			zone := world.World.Zones[i]
+			powerRequired := world.ZonePowerRequirement[zone.Type]
 
-					_, _, found := astar.Path(from, to)
-					if found {
-						powerRemaining[j] -= powerRequired
-						powered = true
-						break FINDPOWERPATH
-					}
-				}
+			supplier := suppliers[i]
+			zone.PowerRatio, zone.PowerLoss = 0, 0
+			if supplier.plant != -1 {
+				zone.PowerRatio, zone.PowerLoss = powerRatios[supplier.plant], supplier.loss
+				totalPowerLost += (world.PowerDrawn(powerRequired, supplier.loss) - float64(powerRequired)) * zone.PowerRatio
+			}
+			zone.Powered = zone.PowerRatio > 0
+			if !zone.Powered {
+				world.World.PowerOuts[zone.X][zone.Y] = true
+				world.World.HavePowerOut = true
+			} else if zone.PowerRatio < 1 {
+				world.World.Brownouts[zone.X][zone.Y] = true
+				world.World.HaveBrownout = true
 			}
-		}
-		zone.Powered = powered
-		if !powered {
-			havePowerOut = true
-			world.World.PowerOuts[zone.X][zone.Y] = true
-			world.World.HavePowerOut = true
-		}
-
-		totalPowerRequired += powerRequired
-	}
 
-	if !havePowerOut {
-		world.World.PowerUpdated = false
+			totalPowerRequired += powerRequired
+		}
 	}
 
 	world.World.PowerAvailable, world.World.PowerNeeded = totalPowerAvailable, totalPowerRequired
+	world.World.PowerLost = int(math.Round(totalPowerLost))
 
 	return nil
 }
-
-func (s *PowerScanSystem) Draw(e gohan.Entity, screen *ebiten.Image) error {
-	return gohan.ErrUnregister
-}
@@ -0,0 +1,204 @@
+package system
+
+import (
+	"math"
+	"testing"
+
+	"code.rocketnine.space/tslocum/citylimits/world"
+)
+
+// newFlatCity resets the world to a flat, empty city of the specified size.
+func newFlatCity(width int, height int) {
+	world.World.MapSeed = 1
+	world.World.MapWidth, world.World.MapHeight = width, height
+	world.Reset()
+	world.World.Funds = 1000000000
+
+	level := world.World.Level
+	for x := range level.Tiles[0] {
+		for _, tile := range level.Tiles[0][x] {
+			tile.EnvironmentSprite = world.TileGID(world.DirtTile)
+		}
+	}
+}
+
+// applyCommands applies commands immediately.
+func applyCommands(tb testing.TB, commands ...*world.Command) {
+	for _, c := range commands {
+		err := world.ApplyCommand(c)
+		if err != nil {
+			tb.Fatalf("%s: %s", c, err)
+		}
+	}
+}
+
+// newPowerScanCity builds a 256x256 city of about 10,000 residential zones
+// along parallel roads, supplied by 51 nuclear power plants along a road on
+// the left edge of the map.
+func newPowerScanCity(tb testing.TB) {
+	newFlatCity(256, 256)
+
+	apply := func(c *world.Command) {
+		applyCommands(tb, c)
+	}
+
+	// Roads and zones are two tiles wide. Each road is lined with zones on
+	// both sides.
+	apply(&world.Command{Type: world.CommandBuildRoad, X: 6, Y: 1, ToX: 6, ToY: 255})
+	for y := 3; y < 256; y += 6 {
+		apply(&world.Command{Type: world.CommandBuildRoad, X: 7, Y: y, ToX: 255, ToY: y})
+	}
+	for y := 1; y < 256; y += 2 {
+		if y%6 == 3 {
+			continue
+		}
+		for x := 8; x < 256; x += 2 {
+			apply(&world.Command{Type: world.CommandBuildStructure, StructureType: world.StructureResidentialZone, X: x, Y: y})
+		}
+	}
+	for y := 4; y < 256; y += 5 {
+		apply(&world.Command{Type: world.CommandBuildStructure, StructureType: world.StructurePowerPlantNuclear, X: 4, Y: y})
+	}
+}
+
+func BenchmarkPowerScan(b *testing.B) {
+	newPowerScanCity(b)
+	b.Logf("%d zones, %d power plants", len(world.World.Zones), len(world.World.PowerPlants))
+
+	s := NewPowerScanSystem()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		world.World.PowerUpdated = true
+		err := s.Update()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPowerScanLoss(t *testing.T) {
+	tests := []struct {
+		name    string
+		command *world.Command
+		loss    int // Loss per tile
+	}{
+		{"road", &world.Command{Type: world.CommandBuildRoad, X: 5, Y: 7, ToX: 60, ToY: 7}, world.PowerLoss},
+		{"power line", &world.Command{Type: world.CommandBuildPowerLine, X: 5, Y: 6, ToX: 60, ToY: 6}, world.PowerLoss},
+		{"high-voltage line", &world.Command{Type: world.CommandBuildPowerLine, StructureType: world.StructureHighVoltageLine, X: 5, Y: 6, ToX: 60, ToY: 6}, world.HighVoltagePowerLoss},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newFlatCity(64, 16)
+
+			// Zones are built along a line leading from a power plant, spaced
+			// so that power is not carried from one zone to the next.
+			applyCommands(t,
+				&world.Command{Type: world.CommandBuildStructure, StructureType: world.StructurePowerPlantCoal, X: 4, Y: 8},
+				test.command,
+			)
+			for _, x := range []int{10, 20, 30, 40, 50} {
+				applyCommands(t, &world.Command{Type: world.CommandBuildStructure, StructureType: world.StructureResidentialZone, X: x, Y: 5})
+			}
+
+			err := NewPowerScanSystem().Update()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(world.World.Zones) != 5 {
+				t.Fatalf("expected 5 zones, got %d", len(world.World.Zones))
+			}
+			for i, zone := range world.World.Zones {
+				if !zone.Powered || zone.PowerRatio != 1 {
+					t.Fatalf("zone %d,%d: expected full power, got ratio %f", zone.X, zone.Y, zone.PowerRatio)
+				}
+				if i == 0 {
+					continue
+				}
+				previous := world.World.Zones[i-1]
+				if zone.PowerLoss-previous.PowerLoss != 10*test.loss {
+					t.Errorf("zone %d,%d: expected loss %d greater than zone %d,%d, got %d and %d", zone.X, zone.Y, 10*test.loss, previous.X, previous.Y, zone.PowerLoss, previous.PowerLoss)
+				}
+			}
+		})
+	}
+}
+
+func TestPowerScanAllocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		columns  int // Columns of zones on each side of the road
+		priority int
+
+		priorityFull bool // Whether priority zones receive full power
+		othersFull   bool // Whether the remaining zones receive full power
+	}{
+		{"supply exceeds demand", 8, 0, true, true},
+		{"shared fairly", 28, 0, false, false},
+		{"residential first", 28, world.StructureResidentialZone, true, false},
+		{"commercial first", 28, world.StructureCommercialZone, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newFlatCity(64, 32)
+
+			// Residential zones are built on one side of a road leading from
+			// a solar power plant, and commercial zones on the other.
+			applyCommands(t,
+				&world.Command{Type: world.CommandBuildStructure, StructureType: world.StructurePowerPlantSolar, X: 4, Y: 12},
+				&world.Command{Type: world.CommandBuildRoad, X: 6, Y: 11, ToX: 6 + test.columns*2, ToY: 11},
+				&world.Command{Type: world.CommandSetPowerPriority, StructureType: test.priority},
+			)
+			for i := 0; i < test.columns; i++ {
+				applyCommands(t,
+					&world.Command{Type: world.CommandBuildStructure, StructureType: world.StructureResidentialZone, X: 8 + i*2, Y: 9},
+					&world.Command{Type: world.CommandBuildStructure, StructureType: world.StructureCommercialZone, X: 8 + i*2, Y: 13},
+				)
+			}
+
+			err := NewPowerScanSystem().Update()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(world.World.Zones) != test.columns*2 || len(world.World.PowerPlants) != 1 {
+				t.Fatalf("expected %d zones and 1 power plant, got %d and %d", test.columns*2, len(world.World.Zones), len(world.World.PowerPlants))
+			}
+			plant := world.World.PowerPlants[0]
+			var drawn float64
+			ratios := make(map[bool]float64) // By whether the zone has priority
+			for _, zone := range world.World.Zones {
+				drawn += world.PowerDrawn(world.ZonePowerRequirement[zone.Type], zone.PowerLoss) * zone.PowerRatio
+
+				priority := zone.Type == test.priority
+				full := test.othersFull
+				if priority {
+					full = test.priorityFull
+				}
+				if full != (zone.PowerRatio == 1) {
+					t.Fatalf("zone %d,%d: expected full power %v, got ratio %f", zone.X, zone.Y, full, zone.PowerRatio)
+				}
+				if !zone.Powered {
+					t.Fatalf("zone %d,%d: expected power, got none", zone.X, zone.Y)
+				}
+
+				// Zones which share power receive the same fraction of the
+				// power they require.
+				if ratio, ok := ratios[priority]; ok && math.Abs(ratio-zone.PowerRatio) > 1e-9 {
+					t.Fatalf("zone %d,%d: expected ratio %f, got %f", zone.X, zone.Y, ratio, zone.PowerRatio)
+				}
+				ratios[priority] = zone.PowerRatio
+			}
+
+			if drawn > float64(plant.Capacity())+1e-6 {
+				t.Errorf("expected at most %d power drawn, got %f", plant.Capacity(), drawn)
+			}
+			if !test.othersFull && math.Abs(drawn-float64(plant.Capacity())) > 1e-6 {
+				t.Errorf("expected all %d power to be drawn while short of power, got %f", plant.Capacity(), drawn)
+			}
+			if world.World.HaveBrownout == test.othersFull {
+				t.Errorf("expected brownout %v, got %v", !test.othersFull, world.World.HaveBrownout)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package system
+
+import (
+	"code.rocketnine.space/tslocum/citylimits/world"
+)
+
+// Simulation advances a city without opening a window, rendering or handling
+// input. It is used to run the simulation on machines without a display.
+type Simulation struct {
+	systems []System
+}
+
+// NewSimulation returns a new headless simulation. The city to simulate may be
+// loaded via world.LoadCity after the simulation is created.
+func NewSimulation() *Simulation {
+	world.Reset()
+
+	world.World.MuteMusic = true
+	world.World.MuteSoundEffects = true
+	world.World.GameStarted = true
+	world.World.ResetGame = false
+
+	world.GenerateTerrain()
+	world.BeginCity()
+
+	return &Simulation{
+		systems: NewSimulationSystems(),
+	}
+}
+
+// Update advances the simulation by a single tick.
+func (s *Simulation) Update() error {
+	for _, system := range s.systems {
+		err := system.Update()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewSimulationSystems returns the systems which advance the simulation, in
+// the order they are updated.
+func NewSimulationSystems() []System {
+	return []System{
+		NewCommandSystem(),
+		NewTickSystem(),
+		NewPowerScanSystem(),
+		NewPopulateSystem(),
+		NewCrimeSystem(),
+		NewFireSystem(),
+		NewPollutionSystem(),
+		NewTaxSystem(),
+	}
+}
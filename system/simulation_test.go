@@ -0,0 +1,73 @@
+package system
+
+import (
+	"bytes"
+	"testing"
+
+	"code.rocketnine.space/tslocum/citylimits/world"
+)
+
+// simulateCity builds a small city on generated terrain and simulates it for a
+// year, returning the city as it is saved.
+func simulateCity(t *testing.T, seed int64) []byte {
+	world.World.MapSeed = seed
+	world.World.MapWidth, world.World.MapHeight = 48, 48
+	world.World.TerrainPreset = world.DefaultTerrainPreset
+	sim := NewSimulation()
+	world.World.Funds = 100000
+
+	// Flatten and clear the site of the city.
+	level := world.World.Level
+	for x := 2; x < 46; x++ {
+		for y := 14; y < 32; y++ {
+			level.Tiles[0][x][y].EnvironmentSprite = world.TileGID(world.DirtTile)
+			level.Tiles[0][x][y].Height = 0
+			level.Tiles[1][x][y].EnvironmentSprite = 0
+		}
+	}
+
+	world.QueueCommand(&world.Command{Type: world.CommandBuildRoad, X: 10, Y: 20, ToX: 44, ToY: 20})
+	for x := 12; x < 44; x += 2 {
+		world.QueueCommand(&world.Command{Type: world.CommandBuildStructure, StructureType: world.StructureResidentialZone, X: x, Y: 18})
+		zoneType := world.StructureIndustrialZone
+		if x%4 == 0 {
+			zoneType = world.StructureCommercialZone
+		}
+		world.QueueCommand(&world.Command{Type: world.CommandBuildStructure, StructureType: zoneType, X: x, Y: 22})
+	}
+	world.QueueCommand(&world.Command{Type: world.CommandBuildStructure, StructureType: world.StructurePowerPlantCoal, X: 8, Y: 22})
+
+	for world.World.Ticks < world.YearTicks {
+		err := sim.Update()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	err := world.SaveCity(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSimulationDeterministic(t *testing.T) {
+	defer func() {
+		world.World.MapSeed = 0
+	}()
+
+	a := simulateCity(t, 42)
+	if r, c, i := world.Population(); r+c+i == 0 {
+		t.Fatal("city did not grow")
+	}
+	b := simulateCity(t, 42)
+	if !bytes.Equal(a, b) {
+		t.Fatal("simulating the same city with the same seed gave different results")
+	}
+
+	c := simulateCity(t, 43)
+	if bytes.Equal(a, c) {
+		t.Error("simulating the same city with seeds 42 and 43 gave the same results")
+	}
+}
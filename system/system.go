@@ -0,0 +1,7 @@
+package system
+
+// System is a simulation system. Systems are updated once per tick and do not
+// render or handle input, allowing the simulation to run without a display.
+type System interface {
+	Update() error
+}
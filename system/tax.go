@@ -1,17 +1,13 @@
 package system
 
 import (
-	"code.rocketnine.space/tslocum/citylimits/component"
+	"fmt"
+	"strings"
+
 	"code.rocketnine.space/tslocum/citylimits/world"
-	"code.rocketnine.space/tslocum/gohan"
-	"github.com/hajimehoshi/ebiten/v2"
 )
 
-type TaxSystem struct {
-	Position *component.Position
-	Velocity *component.Velocity
-	Weapon   *component.Weapon
-}
+type TaxSystem struct{}
 
 func NewTaxSystem() *TaxSystem {
 	s := &TaxSystem{}
@@ -19,11 +15,17 @@ func NewTaxSystem() *TaxSystem {
 	return s
 }
 
-func (s *TaxSystem) Update(_ gohan.Entity) error {
+func (s *TaxSystem) Update() error {
 	if world.World.Paused {
 		return nil
 	}
 
+	if world.World.Ticks%world.MonthTicks != 0 {
+		return nil
+	}
+
+	s.payPowerPlantExpenses()
+
 	if world.World.Ticks%world.YearTicks != 0 {
 		return nil
 	}
@@ -46,6 +48,28 @@ func (s *TaxSystem) Update(_ gohan.Entity) error {
 	return nil
 }
 
-func (s *TaxSystem) Draw(_ gohan.Entity, _ *ebiten.Image) error {
-	return gohan.ErrUnregister
+// payPowerPlantExpenses pays the monthly operating and fuel costs of each
+// power plant and ages them.
+func (s *TaxSystem) payPowerPlantExpenses() {
+	var expenses int
+	for _, plant := range world.World.PowerPlants {
+		if plant.Expired() {
+			continue
+		}
+		expenses += plant.Expenses()
+
+		capacity := plant.Capacity()
+		plant.Age++
+		if plant.Capacity() != capacity {
+			world.World.PowerUpdated = true
+		}
+		if structure := world.StructureAt(plant.X, plant.Y); structure != nil && plant.Expired() {
+			name := structureName(structure)
+			world.ShowMessage(fmt.Sprintf("%s%s is worn out and must be rebuilt", strings.ToUpper(name[:1]), name[1:]), 5)
+		}
+	}
+	if expenses > 0 {
+		world.World.Funds -= expenses
+		world.World.HUDUpdated = true
+	}
 }
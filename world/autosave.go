@@ -0,0 +1,145 @@
+package world
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// autosaveSlots is the number of autosave files rotated between.
+const autosaveSlots = 3
+
+const (
+	emergencySaveFile = "emergency.sav"
+	sessionFile       = "session.lock"
+)
+
+func autosaveFile(slot int) string {
+	return fmt.Sprintf("autosave%d.sav", slot+1)
+}
+
+// recoveryFiles returns the paths of all files which may be used to recover a city.
+func recoveryFiles(dir string) []string {
+	files := []string{filepath.Join(dir, emergencySaveFile)}
+	for slot := 0; slot < autosaveSlots; slot++ {
+		files = append(files, filepath.Join(dir, autosaveFile(slot)))
+	}
+	return files
+}
+
+// Autosave saves the current city to the least recently written autosave slot.
+func Autosave() error {
+	dir, err := SaveDir()
+	if err != nil {
+		return err
+	}
+
+	var (
+		slotPath string
+		slotTime int64
+	)
+	for slot := 0; slot < autosaveSlots; slot++ {
+		p := filepath.Join(dir, autosaveFile(slot))
+		info, err := os.Stat(p)
+		if err != nil {
+			slotPath = p // Unused slot.
+			break
+		}
+		if slotPath == "" || info.ModTime().UnixNano() < slotTime {
+			slotPath, slotTime = p, info.ModTime().UnixNano()
+		}
+	}
+
+	err = SaveCityFile(slotPath)
+	if err != nil {
+		return err
+	}
+	return saveRecording()
+}
+
+// EmergencySave attempts to save the current city after an unrecoverable error.
+// The world may be in an inconsistent state, so any panic is suppressed.
+func EmergencySave() {
+	defer func() {
+		recover()
+	}()
+
+	dir, err := SaveDir()
+	if err != nil {
+		return
+	}
+	SaveCityFile(filepath.Join(dir, emergencySaveFile))
+	saveRecording()
+}
+
+// BeginSession marks the start of a game session. When the previous session
+// did not end normally, the most recent save which may be used to recover the
+// city is returned.
+func BeginSession() (recoveryFile string) {
+	dir, err := SaveDir()
+	if err != nil {
+		return ""
+	}
+
+	sessionPath := filepath.Join(dir, sessionFile)
+	_, err = os.Stat(sessionPath)
+	if err == nil {
+		var newest int64
+		for _, p := range recoveryFiles(dir) {
+			info, err := os.Stat(p)
+			if err != nil {
+				continue
+			}
+			if recoveryFile == "" || info.ModTime().UnixNano() > newest {
+				recoveryFile, newest = p, info.ModTime().UnixNano()
+			}
+		}
+	}
+
+	f, err := os.Create(sessionPath)
+	if err == nil {
+		f.Close()
+	}
+	return recoveryFile
+}
+
+// EndSession saves the current city and marks the end of the game session.
+func EndSession() {
+	if World.GameStarted && !World.ResetGame && !World.Replaying {
+		// The city is saved when the game exits even when periodic
+		// autosaving is disabled.
+		err := Autosave()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to save city: %s\n", err)
+			return // Keep the session marked as unfinished.
+		}
+	}
+
+	dir, err := SaveDir()
+	if err != nil {
+		return
+	}
+	os.Remove(filepath.Join(dir, sessionFile))
+	os.Remove(filepath.Join(dir, emergencySaveFile))
+}
+
+// Recover loads the city offered for recovery at startup.
+func Recover() {
+	filePath := World.RecoveryFile
+	World.RecoveryFile = ""
+	World.HUDUpdated = true
+
+	err := LoadCityFile(filePath)
+	if err != nil {
+		ShowMessage(fmt.Sprintf("Failed to recover city: %s", err), 5)
+		return
+	}
+	StartRecording()
+	ShowMessage("Recovered city", 3)
+}
+
+// DismissRecovery declines recovering the city offered for recovery at startup.
+func DismissRecovery() {
+	World.RecoveryFile = ""
+	World.HUDUpdated = true
+}
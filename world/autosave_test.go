@@ -0,0 +1,45 @@
+package world
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEndSession(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("HOME", configDir)
+	dir, err := SaveDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTestCity(t)
+	World.GameStarted, World.ResetGame = true, false
+	World.AutosaveMonths = 0
+	defer func() {
+		World.GameStarted, World.ResetGame = false, true
+	}()
+
+	if recoveryFile := BeginSession(); recoveryFile != "" {
+		t.Fatalf("expected no recovery file, got %s", recoveryFile)
+	}
+
+	// The city is saved when the session ends even when autosaving is disabled.
+	EndSession()
+	_, err = os.Stat(filepath.Join(dir, autosaveFile(0)))
+	if err != nil {
+		t.Fatalf("city was not saved: %s", err)
+	}
+	_, err = os.Stat(filepath.Join(dir, sessionFile))
+	if !os.IsNotExist(err) {
+		t.Fatalf("session was not ended: %v", err)
+	}
+
+	// The save is offered for recovery when a session does not end normally.
+	BeginSession()
+	if recoveryFile := BeginSession(); recoveryFile != filepath.Join(dir, autosaveFile(0)) {
+		t.Errorf("expected recovery file %s, got %s", filepath.Join(dir, autosaveFile(0)), recoveryFile)
+	}
+}
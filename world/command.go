@@ -0,0 +1,373 @@
+package world
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Command types. All player actions which modify the world are expressed as
+// commands and applied via the command queue.
+const (
+	CommandBuildStructure           = iota + 1 // Build StructureType at X,Y
+	CommandBulldoze                            // Bulldoze X,Y
+	CommandBuildRoad                           // Build road from X,Y to ToX,ToY
+	CommandSetTax                              // Set tax rate of zone StructureType to Value
+	CommandSetTransparentStructures            // Enable (Value 1) or disable (Value 0) transparent structures
+	CommandUndo                                // Undo the last build or bulldoze command
+	CommandRedo                                // Redo the last undone command
+	CommandSetFunding                          // Set funding of service StructureType to Value
+	CommandBuildPowerLine                      // Build power line of StructureType from X,Y to ToX,ToY
+	CommandSetPowerPriority                    // Supply zones of StructureType with power first, or share power fairly when 0
+)
+
+var commandNames = map[int]string{
+	CommandBuildStructure:           "build",
+	CommandBulldoze:                 "bulldoze",
+	CommandBuildRoad:                "road",
+	CommandSetTax:                   "tax",
+	CommandSetTransparentStructures: "transparency",
+	CommandUndo:                     "undo",
+	CommandRedo:                     "redo",
+	CommandSetFunding:               "funding",
+	CommandBuildPowerLine:           "powerline",
+	CommandSetPowerPriority:         "powerpriority",
+}
+
+// Command is an action which modifies the world.
+type Command struct {
+	Type int
+	Tick int // Tick at which the command is applied
+
+	StructureType int
+	X, Y          int
+	ToX, ToY      int
+	Value         float64
+
+	applied bool  // Whether the command has been applied
+	err     error // Error returned when the command was applied
+}
+
+// Result returns whether the command has been applied, and the error returned
+// when it was applied.
+func (c *Command) Result() (applied bool, err error) {
+	return c.applied, c.err
+}
+
+func (c *Command) String() string {
+	switch c.Type {
+	case CommandBuildStructure:
+		return fmt.Sprintf("%s %s at %d,%d", commandNames[c.Type], strings.ToLower(StructureTooltips[c.StructureType]), c.X, c.Y)
+	case CommandBulldoze:
+		return fmt.Sprintf("%s %d,%d", commandNames[c.Type], c.X, c.Y)
+	case CommandBuildRoad, CommandBuildPowerLine:
+		return fmt.Sprintf("%s from %d,%d to %d,%d", commandNames[c.Type], c.X, c.Y, c.ToX, c.ToY)
+	case CommandSetTax, CommandSetFunding:
+		return fmt.Sprintf("%s %s %.2f", commandNames[c.Type], strings.ToLower(StructureTooltips[c.StructureType]), c.Value)
+	case CommandSetTransparentStructures:
+		return fmt.Sprintf("%s %.0f", commandNames[c.Type], c.Value)
+	case CommandSetPowerPriority:
+		if c.StructureType == 0 {
+			return fmt.Sprintf("%s fair", commandNames[c.Type])
+		}
+		return fmt.Sprintf("%s %s", commandNames[c.Type], strings.ToLower(StructureTooltips[c.StructureType]))
+	case CommandUndo, CommandRedo:
+		return commandNames[c.Type]
+	default:
+		return fmt.Sprintf("unknown command %d", c.Type)
+	}
+}
+
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// QueueCommand queues a command to be applied at the current tick.
+func QueueCommand(c *Command) {
+	if World.Replaying {
+		ShowMessage("Replay in progress", 3)
+		return
+	}
+	c.Tick = World.Ticks
+	World.Commands = append(World.Commands, c)
+}
+
+// ApplyCommands applies all queued commands which are due.
+func ApplyCommands() {
+	var applied int
+	for _, c := range World.Commands {
+		if c.Tick > World.Ticks {
+			break
+		}
+		applied++
+
+		err := ApplyCommand(c)
+		c.applied, c.err = true, err
+		recordCommand(c)
+		if World.Debug > 1 {
+			if err != nil {
+				log.Printf("tick %d: %s: %s", c.Tick, c, err)
+			} else {
+				log.Printf("tick %d: %s", c.Tick, c)
+			}
+		}
+	}
+	if applied == 0 {
+		return
+	}
+	World.Commands = append(World.Commands[:0], World.Commands[applied:]...)
+}
+
+// ValidateCommand returns an error when a command is malformed.
+func ValidateCommand(c *Command) error {
+	switch c.Type {
+	case CommandBuildStructure:
+		if _, ok := StructureCosts[c.StructureType]; !ok || c.StructureType == StructureBulldozer {
+			return fmt.Errorf("invalid structure type %d", c.StructureType)
+		}
+		if !ValidXY(c.X, c.Y) {
+			return errors.New("invalid location")
+		}
+	case CommandBulldoze:
+		if !ValidXY(c.X, c.Y) {
+			return errors.New("invalid location")
+		}
+	case CommandBuildRoad, CommandBuildPowerLine:
+		if !ValidXY(c.X, c.Y) || !ValidXY(c.ToX, c.ToY) {
+			return errors.New("invalid location")
+		}
+		if c.Type == CommandBuildPowerLine && c.StructureType != 0 && !IsPowerLine(c.StructureType) {
+			return fmt.Errorf("invalid power line type %d", c.StructureType)
+		}
+	case CommandSetTax:
+		if !IsZone(c.StructureType) {
+			return fmt.Errorf("invalid zone type %d", c.StructureType)
+		}
+		if c.Value < 0 || c.Value > 1 {
+			return fmt.Errorf("invalid tax rate %f", c.Value)
+		}
+	case CommandSetFunding:
+		if !IsService(c.StructureType) {
+			return fmt.Errorf("invalid service type %d", c.StructureType)
+		}
+		if c.Value < 0 || c.Value > 1 {
+			return fmt.Errorf("invalid funding %f", c.Value)
+		}
+	case CommandSetPowerPriority:
+		if c.StructureType != 0 && !IsZone(c.StructureType) {
+			return fmt.Errorf("invalid zone type %d", c.StructureType)
+		}
+	case CommandSetTransparentStructures, CommandUndo, CommandRedo:
+	default:
+		return fmt.Errorf("unknown command type %d", c.Type)
+	}
+	return nil
+}
+
+// ApplyCommand validates and applies a command immediately.
+func ApplyCommand(c *Command) error {
+	err := ValidateCommand(c)
+	if err != nil {
+		return err
+	}
+
+	if isUndoable(c) {
+		record := beginUndoRecord(c)
+		err = applyCommand(c)
+		if finishUndoRecord(record) {
+			World.redoHistory = nil
+		}
+		return err
+	}
+	return applyCommand(c)
+}
+
+func applyCommand(c *Command) error {
+	var err error
+	switch c.Type {
+	case CommandBuildStructure:
+		cost := BuildCost(c.StructureType, c.X, c.Y)
+		_, err = buildCommand(c.StructureType, c.X, c.Y, true)
+		if err == nil {
+			ShowBuildCost(c.StructureType, cost)
+		}
+		return err
+	case CommandBulldoze:
+		cost := BuildCost(StructureBulldozer, c.X, c.Y)
+		_, err = buildCommand(StructureBulldozer, c.X, c.Y, true)
+		if err == nil {
+			ShowBuildCost(StructureBulldozer, cost)
+		}
+		return err
+	case CommandBuildRoad:
+		return buildLine(StructureRoad, c)
+	case CommandBuildPowerLine:
+		structureType := c.StructureType
+		if structureType == 0 {
+			structureType = StructurePowerLine
+		}
+		return buildLine(structureType, c)
+	case CommandSetTax:
+		switch c.StructureType {
+		case StructureResidentialZone:
+			World.TaxR = c.Value
+		case StructureCommercialZone:
+			World.TaxC = c.Value
+		case StructureIndustrialZone:
+			World.TaxI = c.Value
+		}
+		World.HUDUpdated = true
+		return nil
+	case CommandSetFunding:
+		switch c.StructureType {
+		case StructurePoliceStation:
+			World.PoliceFunding = c.Value
+		case StructureFireStation:
+			World.FireFunding = c.Value
+		}
+		World.HUDUpdated = true
+		return nil
+	case CommandSetPowerPriority:
+		World.PowerPriority = c.StructureType
+		World.PowerUpdated = true
+		World.HUDUpdated = true
+
+		if World.PowerPriority == 0 {
+			ShowMessage("Sharing power fairly", 3)
+		} else {
+			ShowMessage(fmt.Sprintf("Supplying %ss first", strings.ToLower(StructureTooltips[World.PowerPriority])), 3)
+		}
+		return nil
+	case CommandSetTransparentStructures:
+		World.TransparentStructures = c.Value != 0
+		World.HUDUpdated = true
+
+		if World.TransparentStructures {
+			ShowMessage("Enabled transparency", 3)
+		} else {
+			ShowMessage("Disabled transparency", 3)
+		}
+		return nil
+	case CommandUndo:
+		return undo()
+	case CommandRedo:
+		return redo()
+	}
+	return nil
+}
+
+// buildLine builds a road or power line from X,Y to ToX,ToY, skipping tiles
+// which may not be built on.
+func buildLine(structureType int, c *Command) error {
+	tiles := RoadTiles(c.X, c.Y, c.ToX, c.ToY)
+	var cost int
+	for _, tile := range tiles {
+		cost += BuildCost(structureType, tile[0], tile[1])
+	}
+	if cost/2 > World.Funds {
+		ShowMessage("Insufficient funds", 3)
+		return ErrInsufficientFunds
+	}
+	cost = 0
+	for _, tile := range tiles {
+		tileCost := BuildCost(structureType, tile[0], tile[1])
+		_, err := buildCommand(structureType, tile[0], tile[1], cost == 0)
+		if err == nil {
+			cost += tileCost
+		}
+	}
+	if cost == 0 {
+		return errors.New("invalid location: space already occupied")
+	}
+	ShowBuildCost(structureType, cost)
+	return nil
+}
+
+// buildCommand builds a structure, charging its cost and tracking it for
+// simulation.
+func buildCommand(structureType int, tileX int, tileY int, playSound bool) (*Structure, error) {
+	cost := BuildCost(structureType, tileX, tileY)
+	if World.Funds < cost {
+		ShowMessage("Insufficient funds", 3)
+		return nil, ErrInsufficientFunds
+	}
+
+	structure, err := BuildStructure(structureType, false, tileX, tileY, false)
+	if err == nil || structureType == StructureBulldozer {
+		World.LastBuildX, World.LastBuildY = tileX, tileY
+
+		if IsPowerPlant(structureType) {
+			plant := &PowerPlant{
+				Type: structureType,
+				X:    structure.X,
+				Y:    structure.Y,
+			}
+			World.PowerPlants = append(World.PowerPlants, plant)
+			structure.PowerPlant = plant
+		}
+
+		if IsZone(structureType) {
+			zone := &Zone{
+				Type: structureType,
+				X:    structure.X,
+				Y:    structure.Y,
+			}
+			World.Zones = append(World.Zones, zone)
+			structure.Zone = zone
+		}
+
+		if err == nil && isRegisteredStructure(structureType) {
+			registerStructure(structure)
+		}
+
+		if structureType != StructureBulldozer && playSound {
+			PlaySoundEffect(SoundBuild)
+		}
+
+		if err == nil {
+			World.Funds -= cost
+		}
+
+		World.HUDUpdated = true
+	} else {
+		dX := tileX - World.LastBuildX
+		if dX < 0 {
+			dX *= -1
+		}
+		dY := tileY - World.LastBuildY
+		if dY < 0 {
+			dY *= -1
+		}
+		if (dX > 1 || dY > 1) && err != ErrNothingToBulldoze {
+			errMessage := err.Error()
+			if len(errMessage) > 0 {
+				errMessage = strings.ToUpper(errMessage[0:1]) + errMessage[1:]
+			}
+			ShowMessage(errMessage, 3)
+		}
+	}
+	return structure, err
+}
+
+// RoadTiles returns the tiles in a line of road.
+func RoadTiles(fromX, fromY, toX, toY int) [][2]int {
+	var tiles [][2]int
+	fx, fy := float64(fromX), float64(fromY)
+	tx, ty := float64(toX), float64(toY)
+	dx, dy := tx-fx, ty-fy
+	for dx < -1 || dx > 1 || dy < -1 || dy > 1 {
+		dx /= 2
+		dy /= 2
+	}
+	tiles = append(tiles, [2]int{fromX, fromY})
+	for fx != tx || fy != ty {
+		fx, fy = fx+dx, fy+dy
+		tiles = append(tiles, [2]int{int(fx), int(fy)})
+	}
+	return tiles
+}
+
+// IsMultiUseStructure returns whether a structure remains selected after it is
+// built, allowing it to be built repeatedly by dragging.
+func IsMultiUseStructure(structureType int) bool {
+	return structureType == StructureBulldozer || structureType == StructureRoad || IsPowerLine(structureType) || IsZone(structureType) || IsTerraformTool(structureType)
+}
@@ -0,0 +1,70 @@
+package world
+
+import "errors"
+
+// MaxElevation is the maximum height of terrain in steps.
+const MaxElevation = 8
+
+// ElevationSteps is the number of steps of elevation per layer.
+const ElevationSteps = 2
+
+// ElevationHeight is the height of each step of elevation in pixels.
+const ElevationHeight = LayerHeight / ElevationSteps
+
+// MaxRoadSlope is the maximum difference in elevation between the tiles of a road.
+const MaxRoadSlope = 1
+
+// HeightAt returns the elevation of the ground at the specified location.
+// Locations outside of the level are at an elevation of 0.
+func (l *GameLevel) HeightAt(x int, y int) int {
+	if x < 0 || y < 0 || x >= l.width || y >= l.height {
+		return 0
+	}
+	return l.Tiles[0][x][y].Height
+}
+
+// ElevationOffset returns the vertical offset in pixels at which the tiles at
+// the specified location are drawn.
+func (l *GameLevel) ElevationOffset(x int, y int) float64 {
+	return float64(-l.HeightAt(x, y) * ElevationHeight)
+}
+
+// GroundFill calls fill with each elevation at which an additional ground tile
+// must be drawn beneath the specified location, so that no gap is visible
+// between it and the lower tiles in front of it.
+func (l *GameLevel) GroundFill(x int, y int, fill func(height int)) {
+	bottom := l.HeightAt(x+1, y)
+	if h := l.HeightAt(x, y+1); h < bottom {
+		bottom = h
+	}
+	for h := l.HeightAt(x, y) - ElevationSteps; h > bottom; h -= ElevationSteps {
+		fill(h)
+	}
+}
+
+// terraform raises or lowers the ground at the specified location by a
+// single step.
+func terraform(x int, y int, raise bool) error {
+	for i := range World.Level.Tiles {
+		if World.Level.Tiles[i][x][y].Sprite != 0 {
+			return errors.New("invalid location: space already occupied")
+		}
+	}
+	if World.Level.IsWater(x, y) {
+		return errors.New("invalid location: cannot terraform water")
+	}
+
+	tile := World.Level.Tiles[0][x][y]
+	if raise {
+		if tile.Height >= MaxElevation {
+			return errors.New("invalid location: terrain is already at its highest")
+		}
+		tile.Height++
+	} else {
+		if tile.Height <= 0 {
+			return errors.New("invalid location: terrain is already at its lowest")
+		}
+		tile.Height--
+	}
+	return nil
+}
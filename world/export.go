@@ -0,0 +1,127 @@
+package world
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/image/draw"
+)
+
+// LayerHeight is the vertical distance in pixels between map layers.
+const LayerHeight = 40
+
+// DefaultExportZoom is the zoom level cities are exported at when no zoom
+// level is specified.
+const DefaultExportZoom = 0.25
+
+// ExportImage renders the entire city to an image at the specified zoom level.
+func ExportImage(zoom float64) (*image.RGBA, error) {
+	return RenderLevel(World.Level, zoom)
+}
+
+// RenderLevel renders an entire level to an image at the specified zoom level.
+// Tiles are drawn in the same order as they are drawn on screen. Hover sprites
+// and power outages are not drawn.
+func RenderLevel(level *GameLevel, zoom float64) (*image.RGBA, error) {
+	if zoom <= 0 || zoom > 1 {
+		return nil, fmt.Errorf("invalid zoom level %f", zoom)
+	}
+
+	m, err := LoadMap(StructureResidentialLow)
+	if err != nil {
+		return nil, err
+	}
+	tileset, tilesetImg, err := LoadTilesetImage(m)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := level.width, level.height
+	layers := len(level.Tiles)
+	tileW, tileH := tileset.TileWidth, tileset.TileHeight
+
+	// Bounds of the map in isometric coordinates.
+	minX, _ := CartesianToIso(0, float64(height-1))
+	maxX, _ := CartesianToIso(float64(width-1), 0)
+	_, minY := CartesianToIso(0, 0)
+	_, maxY := CartesianToIso(float64(width-1), float64(height-1))
+	minY -= float64((layers-1)*LayerHeight + MaxElevation*ElevationHeight)
+	maxX += float64(tileW)
+	maxY += float64(tileH)
+
+	img := image.NewRGBA(image.Rect(0, 0, int((maxX-minX)*zoom), int((maxY-minY)*zoom)))
+	for i := range level.Tiles {
+		for x := range level.Tiles[i] {
+			for y, tile := range level.Tiles[i][x] {
+				if tile == nil {
+					continue
+				}
+				var gid uint32
+				if tile.Sprite != 0 {
+					gid = tile.Sprite
+				} else if tile.EnvironmentSprite != 0 {
+					gid = tile.EnvironmentSprite
+				}
+				if gid == 0 || gid == HiddenTile || gid < tileset.FirstGID {
+					continue
+				}
+
+				src := tileset.GetTileRect(gid - tileset.FirstGID)
+				drawTile := func(offsetY float64) {
+					xi, yi := CartesianToIso(float64(x), float64(y))
+					xi, yi = (xi-minX)*zoom, (yi-minY+offsetY)*zoom
+					r := image.Rect(int(xi), int(yi), int(xi+float64(tileW)*zoom), int(yi+float64(tileH)*zoom))
+					draw.NearestNeighbor.Scale(img, r, tilesetImg, src, draw.Over, nil)
+				}
+				if i == 0 {
+					level.GroundFill(x, y, func(height int) {
+						drawTile(float64(-height * ElevationHeight))
+					})
+				}
+				drawTile(float64(-i*LayerHeight) + level.ElevationOffset(x, y))
+			}
+		}
+	}
+	return img, nil
+}
+
+// ExportImageFile renders the entire city to a PNG file.
+func ExportImageFile(filePath string, zoom float64) error {
+	img, err := ExportImage(zoom)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	err = png.Encode(f, img)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// QuickExport renders the entire city to a PNG file in the save directory.
+func QuickExport() {
+	dir, err := SaveDir()
+	if err != nil {
+		ShowMessage(fmt.Sprintf("Failed to export city: %s", err), 5)
+		return
+	}
+
+	fileName := fmt.Sprintf("city-%s.png", time.Now().Format("20060102-150405"))
+	err = ExportImageFile(filepath.Join(dir, fileName), DefaultExportZoom)
+	if err != nil {
+		ShowMessage(fmt.Sprintf("Failed to export city: %s", err), 5)
+		return
+	}
+	ShowMessage(fmt.Sprintf("Exported city to %s", fileName), 3)
+}
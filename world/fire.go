@@ -0,0 +1,70 @@
+package world
+
+// FireStationRadius is the distance in tiles within which a fire station
+// prevents and extinguishes fires.
+const FireStationRadius = 16
+
+// FireStationUpkeep is the monthly cost of each fire station.
+const FireStationUpkeep = 100
+
+// FireDuration is the number of fire steps a structure burns for before it
+// is destroyed.
+const FireDuration = 5
+
+// Fire is a burning structure.
+type Fire struct {
+	X, Y int // Location of the burning structure
+	Age  int // Number of fire steps the structure has burned for
+}
+
+// FireAt returns the fire burning the specified structure, or nil when the
+// structure is not burning.
+func FireAt(s *Structure) *Fire {
+	for _, fire := range World.Fires {
+		if fire.X == s.X && fire.Y == s.Y {
+			return fire
+		}
+	}
+	return nil
+}
+
+// FireCoverage returns how effectively fire stations protect the specified
+// tile, between 0 and 1. Coverage is strongest near a fire station and scales
+// with fire station funding.
+func FireCoverage(x int, y int) float64 {
+	return serviceCoverage(StructureFireStation, FireStationRadius, x, y) * World.FireFunding
+}
+
+// IgniteStructure sets a structure on fire. It returns false when the
+// structure is already burning or may not burn.
+func IgniteStructure(s *Structure) bool {
+	if s.Type == StructureRubble || FireAt(s) != nil {
+		return false
+	}
+	World.Fires = append(World.Fires, &Fire{X: s.X, Y: s.Y})
+	return true
+}
+
+// ExtinguishFire puts out a fire.
+func ExtinguishFire(f *Fire) {
+	for i, fire := range World.Fires {
+		if fire == f {
+			World.Fires = append(World.Fires[:i], World.Fires[i+1:]...)
+			return
+		}
+	}
+}
+
+// BurnDown destroys a structure, leaving rubble which must be bulldozed.
+func BurnDown(s *Structure) {
+	bulldozeStructure(s)
+	r := s.Rect()
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			rubble, err := BuildStructure(StructureRubble, false, x, y, true)
+			if err == nil {
+				registerStructure(rubble)
+			}
+		}
+	}
+}
@@ -2,61 +2,67 @@ package world
 
 // HelpText lines must be 39 characters or less.
 var HelpText = []string{`
-Welcome to City Limits!          (1/10)
+Welcome to City Limits!          (1/11)
 As the new mayor, it's time to run
 things YOUR way. For better or worse...
 Will you lead the clean energy front,
 or will you put profits before people?
 `, `
-Moving Via Mouse                 (2/10)
+Moving Via Mouse                 (2/11)
 To move around, press and hold your
 middle mouse button while moving your
 mouse, or press right click to center
 the camera on an area immediately.
 `, `
-Moving Via Keyboard              (3/10)
+Moving Via Keyboard              (3/11)
 You can also use your keyboard to move
 by pressing any of the arrow keys or
 W/A/S/D. Try using your mouse and/or
 keyboard to move the camera around now.
 `, `
-Zoning Areas                     (4/10)
+Zoning Areas                     (4/11)
 Structures are built according to how
 you zone areas of land. Demand for each
 category (Residential, Commercial,
 Industrial) is shown in the sidebar.
 `, `
-Did You Know?                    (5/10)
+Did You Know?                    (5/11)
 Powering an area requires more input
 energy than the resulting electricity.
 In fact, only a third of the energy is
 transmitted as usable electricity. 
 `, `
-Building Blocks of a City        (6/10)
+Building Blocks of a City        (6/11)
 Structures require power, sewer and
 transportation in order to function.
 This is all facilitated via roads and
 underground wiring and piping.
 `, `
-Did You Know?                    (7/10)
+Did You Know?                    (7/11)
 Meat-based diets require much more
 energy, land and water resources than
 vegetarian and especially vegan diets.
 Animal farms are a source of pollution.
 `, `
-Power to the People              (8/10)
+Power to the People              (8/11)
 Build a power plant, then zone a few
 areas for residential development.
 Connect the power plant to the newly
 zoned areas with a road.
 `, `
-Did You Know?                    (9/10)
+Did You Know?                    (9/11)
 It takes large amounts of water to
 convert fossil fuels into electricity.
 Converting solar and wind energy uses
 negligible amounts of water.
 `, `
-Collecting Taxes                (10/10)
+Clearing the Air                (10/11)
+Coal plants, industry and traffic
+pollute the air around them. Residents
+avoid polluted areas and their health
+suffers. Solar and nuclear are clean.
+`, `
+Collecting Taxes                (11/11)
 Each year, taxes are collected from the
 residents of the city. The tax rate you
 set determines how appealing your city
@@ -1,24 +1,44 @@
 package world
 
 import (
-	"github.com/hajimehoshi/ebiten/v2"
+	"fmt"
+	"strconv"
+	"strings"
 )
 
+// HiddenTile may be set as a HoverSprite to hide a tile temporarily.
+const HiddenTile = ^uint32(0)
+
+// Tile is a single tile of a level layer. Sprites are stored as tile GIDs,
+// where 0 represents no sprite.
 type Tile struct {
-	Sprite            *ebiten.Image
-	EnvironmentSprite *ebiten.Image
-	HoverSprite       *ebiten.Image
+	Sprite            uint32
+	EnvironmentSprite uint32
+	HoverSprite       uint32
+
+	Structure int // Type of structure occupying the tile (set on the ground layer only)
+	Height    int // Elevation of the tile in steps (set on the ground layer only)
 }
 
+// DefaultMapSize is the width and height of new maps when no size is specified.
+const DefaultMapSize = 256
+
+// Limits of the width and height of maps.
+const (
+	MinMapSize = 16
+	MaxMapSize = 1024
+)
+
 type GameLevel struct {
-	Tiles [][][]*Tile
+	Tiles [][][]*Tile // Indexed by layer, x and y
 
-	size int
+	width, height int
 }
 
-func NewLevel(size int) *GameLevel {
+func NewLevel(width int, height int) *GameLevel {
 	l := &GameLevel{
-		size: size,
+		width:  width,
+		height: height,
 	}
 	const startingLayers = 2
 	for i := 0; i < startingLayers; i++ {
@@ -28,10 +48,10 @@ func NewLevel(size int) *GameLevel {
 }
 
 func (l *GameLevel) AddLayer() {
-	tileMap := make([][]*Tile, l.size)
-	for x := 0; x < l.size; x++ {
-		tileMap[x] = make([]*Tile, l.size)
-		for y := 0; y < l.size; y++ {
+	tileMap := make([][]*Tile, l.width)
+	for x := 0; x < l.width; x++ {
+		tileMap[x] = make([]*Tile, l.height)
+		for y := 0; y < l.height; y++ {
 			tileMap[x][y] = &Tile{}
 		}
 	}
@@ -45,8 +65,38 @@ func (l *GameLevel) ClearHoverSprites() {
 				if tile == nil {
 					continue
 				}
-				tile.HoverSprite = nil
+				tile.HoverSprite = 0
 			}
 		}
 	}
 }
+
+// Width returns the width of the level in tiles.
+func (l *GameLevel) Width() int {
+	return l.width
+}
+
+// Height returns the height of the level in tiles.
+func (l *GameLevel) Height() int {
+	return l.height
+}
+
+// ParseMapSize parses a map size specified as a single number (for square
+// maps) or as WIDTHxHEIGHT.
+func ParseMapSize(s string) (width int, height int, err error) {
+	w, h := s, s
+	if i := strings.IndexAny(s, "xX"); i != -1 {
+		w, h = s[:i], s[i+1:]
+	}
+	width, err = strconv.Atoi(w)
+	if err == nil {
+		height, err = strconv.Atoi(h)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid map size %q", s)
+	}
+	if width < MinMapSize || height < MinMapSize || width > MaxMapSize || height > MaxMapSize {
+		return 0, 0, fmt.Errorf("invalid map size %q: width and height must be between %d and %d", s, MinMapSize, MaxMapSize)
+	}
+	return width, height, nil
+}
@@ -0,0 +1,47 @@
+package world
+
+// PoliceStationRadius is the distance in tiles within which a police station
+// reduces crime.
+const PoliceStationRadius = 16
+
+// PoliceStationUpkeep is the monthly cost of each police station while it is
+// fully funded.
+const PoliceStationUpkeep = 100
+
+// MaxCrime is the highest crime rate of a zone.
+const MaxCrime = 100
+
+// HighCrime is the crime rate at which residential zones stop growing.
+const HighCrime = 50
+
+// Unemployment returns the fraction of residents without a job.
+func Unemployment() float64 {
+	popR, popC, popI := Population()
+	if popR == 0 || popC+popI >= popR {
+		return 0
+	}
+	return float64(popR-popC-popI) / float64(popR)
+}
+
+// PoliceCoverage returns how effectively the police protect the specified
+// tile, between 0 and 1. Coverage is strongest near a police station and
+// scales with police funding.
+func PoliceCoverage(x int, y int) float64 {
+	return serviceCoverage(StructurePoliceStation, PoliceStationRadius, x, y) * World.PoliceFunding
+}
+
+// CrimeRate returns the average crime rate of populated zones.
+func CrimeRate() int {
+	var crime, zones int
+	for _, zone := range World.Zones {
+		if zone.Population == 0 {
+			continue
+		}
+		crime += zone.Crime
+		zones++
+	}
+	if zones == 0 {
+		return 0
+	}
+	return crime / zones
+}
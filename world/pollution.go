@@ -0,0 +1,125 @@
+package world
+
+// MaxPollution is the highest level of pollution of a tile.
+const MaxPollution = 100
+
+// HighPollution is the level of pollution at which residential zones stop
+// growing.
+const HighPollution = 40
+
+// UnhealthyPollution is the level of pollution at which residents become ill
+// and move away.
+const UnhealthyPollution = 70
+
+// Pollution emitted by each source every month.
+const (
+	coalPollution       = 30  // Per tile of a coal power plant
+	industrialPollution = 2   // Per industrial worker, per tile of the zone
+	trafficPollution    = 0.5 // Per resident or worker, per adjacent road tile
+)
+
+// pollutionSpread is the fraction of the pollution of each tile which is
+// exchanged with its neighbors each month.
+const pollutionSpread = 0.8
+
+// pollutionDecay is the fraction of pollution remaining after each month.
+const pollutionDecay = 0.9
+
+// PollutionMap holds the level of pollution of each tile, indexed by x and y.
+type PollutionMap [][]float64
+
+func newPollutionMap(width int, height int) PollutionMap {
+	m := make(PollutionMap, width)
+	for x := 0; x < width; x++ {
+		m[x] = make([]float64, height)
+	}
+	return m
+}
+
+// PollutionAt returns the level of pollution at the specified tile.
+func PollutionAt(x int, y int) int {
+	if !ValidXY(x, y) {
+		return 0
+	}
+	return int(World.Pollution[x][y])
+}
+
+// ZonePollution returns the average level of pollution of the tiles occupied
+// by a zone.
+func ZonePollution(zone *Zone) int {
+	s := StructureAt(zone.X, zone.Y)
+	if s == nil {
+		return PollutionAt(zone.X, zone.Y)
+	}
+	var total float64
+	r := s.Rect()
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			total += World.Pollution[x][y]
+		}
+	}
+	return int(total / float64(r.Dx()*r.Dy()))
+}
+
+// emitPollution returns the pollution emitted by each tile this month. Coal
+// power plants and industry pollute the tiles they occupy, while traffic
+// pollutes the roads next to populated zones. Solar and nuclear power plants
+// do not pollute.
+func emitPollution() PollutionMap {
+	emission := newPollutionMap(World.Level.width, World.Level.height)
+	emit := func(s *Structure, amount float64) {
+		r := s.Rect()
+		for x := r.Min.X; x < r.Max.X; x++ {
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				emission[x][y] += amount
+			}
+		}
+	}
+	for _, plant := range World.PowerPlants {
+		if plant.Type != StructurePowerPlantCoal || plant.Expired() {
+			continue
+		}
+		if s := StructureAt(plant.X, plant.Y); s != nil {
+			emit(s, coalPollution)
+		}
+	}
+	for _, zone := range World.Zones {
+		s := StructureAt(zone.X, zone.Y)
+		if s == nil || zone.Population == 0 {
+			continue
+		}
+		if zone.Type == StructureIndustrialZone {
+			emit(s, industrialPollution*float64(zone.Population))
+		}
+		for _, p := range s.AdjacentTiles() {
+			if World.Level.Tiles[0][p.X][p.Y].Structure == StructureRoad {
+				emission[p.X][p.Y] += trafficPollution * float64(zone.Population)
+			}
+		}
+	}
+	return emission
+}
+
+// UpdatePollution advances pollution by a month. Pollution spreads to
+// neighboring tiles and decays over time.
+func UpdatePollution() {
+	width, height := World.Level.width, World.Level.height
+	emission := emitPollution()
+	next := newPollutionMap(width, height)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			var neighbors float64
+			for _, p := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if ValidXY(p[0], p[1]) {
+					neighbors += World.Pollution[p[0]][p[1]]
+				}
+			}
+			v := (World.Pollution[x][y]*(1-pollutionSpread)+neighbors/4*pollutionSpread)*pollutionDecay + emission[x][y]
+			if v > MaxPollution {
+				v = MaxPollution
+			}
+			next[x][y] = v
+		}
+	}
+	World.Pollution = next
+}
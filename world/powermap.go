@@ -1,15 +1,25 @@
 package world
 
-import (
-	"github.com/beefsack/go-astar"
-)
-
 type PowerMapTile struct {
 	X            int
 	Y            int
 	CarriesPower bool // Set to true for roads and all building tiles (even power plants)
+
+	// Network is the power network of the tile. Tiles connected by tiles
+	// which carry power share a network. Network 0 does not carry power.
+	Network int
+
+	loss     int // Transmission loss from the last power plant scanned
+	lossScan int // Scan in which loss was set
 }
 
+// Transmission losses, in tenths of a percent, of power carried by a tile.
+const (
+	PowerLoss            = 5   // Road or power line
+	HighVoltagePowerLoss = 1   // High-voltage line
+	MaxPowerLoss         = 500 // Power is not carried any further
+)
+
 func (t *PowerMapTile) Up() *PowerMapTile {
 	tx, ty := t.X, t.Y-1
 	if !ValidXY(tx, ty) {
@@ -60,11 +70,11 @@ func (t *PowerMapTile) Right() *PowerMapTile {
 
 type PowerMap [][]*PowerMapTile
 
-func newPowerMap() PowerMap {
-	m := make(PowerMap, 256)
-	for x := 0; x < 256; x++ {
-		m[x] = make([]*PowerMapTile, 256)
-		for y := 0; y < 256; y++ {
+func newPowerMap(width int, height int) PowerMap {
+	m := make(PowerMap, width)
+	for x := 0; x < width; x++ {
+		m[x] = make([]*PowerMapTile, height)
+		for y := 0; y < height; y++ {
 			m[x][y] = &PowerMapTile{
 				X: x,
 				Y: y,
@@ -74,21 +84,27 @@ func newPowerMap() PowerMap {
 	return m
 }
 
-func newPowerOuts() [][]bool {
-	m := make([][]bool, 256)
-	for x := 0; x < 256; x++ {
-		m[x] = make([]bool, 256)
+func newPowerOuts(width int, height int) [][]bool {
+	m := make([][]bool, width)
+	for x := 0; x < width; x++ {
+		m[x] = make([]bool, height)
 	}
 	return m
 }
 
 func ResetPowerOuts() {
-	for x := 0; x < 256; x++ {
-		for y := 0; y < 256; y++ {
+	if len(World.PowerOuts) != World.Level.width || len(World.PowerOuts[0]) != World.Level.height {
+		World.PowerOuts = newPowerOuts(World.Level.width, World.Level.height)
+		World.Brownouts = newPowerOuts(World.Level.width, World.Level.height)
+	}
+	for x := range World.PowerOuts {
+		for y := range World.PowerOuts[x] {
 			World.PowerOuts[x][y] = false
+			World.Brownouts[x][y] = false
 		}
 	}
 	World.HavePowerOut = false
+	World.HaveBrownout = false
 }
 
 func (m PowerMap) GetTile(x, y int) *PowerMapTile {
@@ -98,54 +114,300 @@ func (m PowerMap) GetTile(x, y int) *PowerMapTile {
 	return m[x][y]
 }
 
+// SetTile sets whether a tile carries power. Water does not carry power
+// unless it is bridged.
 func (m PowerMap) SetTile(x, y int, carriesPower bool) {
+	if carriesPower && World.Level.IsWater(x, y) && !World.Level.IsBridge(x, y) {
+		carriesPower = false
+	}
 	t := m[x][y]
 	if t.CarriesPower == carriesPower {
 		return
 	}
 	t.CarriesPower = carriesPower
 
+	// Update the affected power networks only, unless every network will be
+	// labeled again anyway.
+	if !World.powerNetworksChanged {
+		if carriesPower {
+			joinPowerNetworks(t)
+		} else {
+			splitPowerNetwork(t)
+		}
+	}
+
 	World.PowerUpdated = true
 }
 
-func (t *PowerMapTile) PathNeighbors() []astar.Pather {
-	var neighbors []astar.Pather
-	n := t.Up()
-	if n != nil {
-		neighbors = append(neighbors, n)
+// neighbors returns the neighboring tiles which carry power.
+func (t *PowerMapTile) neighbors() []*PowerMapTile {
+	var neighbors []*PowerMapTile
+	for _, n := range [4]*PowerMapTile{t.Up(), t.Down(), t.Left(), t.Right()} {
+		if n != nil {
+			neighbors = append(neighbors, n)
+		}
 	}
-	n = t.Down()
-	if n != nil {
-		neighbors = append(neighbors, n)
+	return neighbors
+}
+
+// UpdatePowerNetworks labels the power network of each tile when the power
+// map has been replaced since the networks were last labeled. Changes to
+// individual tiles update the affected networks immediately.
+func UpdatePowerNetworks() {
+	if !World.powerNetworksChanged {
+		return
 	}
-	n = t.Left()
-	if n != nil {
-		neighbors = append(neighbors, n)
+	World.powerNetworksChanged = false
+
+	for x := range World.Power {
+		for _, t := range World.Power[x] {
+			t.Network = 0
+		}
 	}
-	n = t.Right()
-	if n != nil {
-		neighbors = append(neighbors, n)
+	World.powerNetworks = 0
+	for x := range World.Power {
+		for _, t := range World.Power[x] {
+			if t.CarriesPower && t.Network == 0 {
+				World.powerNetworks++
+				fillPowerNetwork(t, 0, World.powerNetworks)
+			}
+		}
 	}
-	return neighbors
 }
 
-func (t *PowerMapTile) PathNeighborCost(to astar.Pather) float64 {
-	toT := to.(*PowerMapTile)
-	if !toT.CarriesPower {
-		return 0
+// fillPowerNetwork moves a tile, and each tile connected to it which belongs
+// to network from, to network to.
+func fillPowerNetwork(t *PowerMapTile, from int, to int) {
+	t.Network = to
+	stack := []*PowerMapTile{t}
+	for len(stack) > 0 {
+		t := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, n := range t.neighbors() {
+			if n.Network == from {
+				n.Network = to
+				stack = append(stack, n)
+			}
+		}
+	}
+}
+
+// joinPowerNetworks adds a tile which now carries power to the network of
+// its neighbors, merging their networks when the tile connects them.
+func joinPowerNetworks(t *PowerMapTile) {
+	t.Network = 0
+	for _, n := range t.neighbors() {
+		if t.Network == 0 {
+			t.Network = n.Network
+		} else if n.Network != t.Network {
+			fillPowerNetwork(n, n.Network, t.Network)
+		}
+	}
+	if t.Network == 0 {
+		World.powerNetworks++
+		t.Network = World.powerNetworks
 	}
-	return 1
 }
 
-func (t *PowerMapTile) PathEstimatedCost(to astar.Pather) float64 {
-	toT := to.(*PowerMapTile)
-	absX := toT.X - t.X
-	if absX < 0 {
-		absX = -absX
+// splitPowerNetwork removes a tile which no longer carries power from its
+// network. The network is searched from each neighbor of the tile in turn
+// until the search of at most one piece remains unfinished. Only the pieces
+// which were searched completely are moved to new networks, so removing a tile
+// from a large network only labels the small pieces split off from it.
+func splitPowerNetwork(t *PowerMapTile) {
+	network := t.Network
+	t.Network = 0
+
+	var neighbors []*PowerMapTile
+	for _, n := range t.neighbors() {
+		if n.Network == network {
+			neighbors = append(neighbors, n)
+		}
+	}
+	if len(neighbors) < 2 {
+		return // The network remains connected.
+	}
+
+	// Searches which reach each other are searching the same piece.
+	owner := make(map[*PowerMapTile]int)
+	pending := make([][]*PowerMapTile, len(neighbors))
+	piece := make([]int, len(neighbors))
+	for i, n := range neighbors {
+		owner[n] = i
+		pending[i] = []*PowerMapTile{n}
+		piece[i] = i
 	}
-	absY := toT.Y - t.Y
-	if absY < 0 {
-		absY = -absY
+	find := func(i int) int {
+		for piece[i] != i {
+			i = piece[i]
+		}
+		return i
+	}
+
+	// unfinishedPieces returns the number of pieces which are still being
+	// searched, and whether each piece has been searched completely.
+	unfinishedPieces := func() (int, []bool) {
+		finished := make([]bool, len(neighbors))
+		for i := range neighbors {
+			finished[i] = true
+		}
+		for i := range neighbors {
+			if len(pending[i]) > 0 {
+				finished[find(i)] = false
+			}
+		}
+		var unfinished int
+		for i := range neighbors {
+			if find(i) == i && !finished[i] {
+				unfinished++
+			}
+		}
+		return unfinished, finished
+	}
+
+	unfinished, finished := unfinishedPieces()
+	for unfinished > 1 {
+		for i := range neighbors {
+			if len(pending[i]) == 0 {
+				continue
+			}
+			tile := pending[i][len(pending[i])-1]
+			pending[i] = pending[i][:len(pending[i])-1]
+			for _, n := range tile.neighbors() {
+				if n.Network != network {
+					continue
+				}
+				if j, ok := owner[n]; ok {
+					if a, b := find(i), find(j); a != b {
+						piece[b] = a
+					}
+					continue
+				}
+				owner[n] = i
+				pending[i] = append(pending[i], n)
+			}
+		}
+		unfinished, finished = unfinishedPieces()
+	}
+
+	// When every piece was searched completely, the first piece remains in
+	// the network.
+	keep := -1
+	if unfinished == 0 {
+		keep = find(0)
+	}
+	labels := make([]int, len(neighbors))
+	for i := range neighbors {
+		if find(i) == i && finished[i] && i != keep {
+			World.powerNetworks++
+			labels[i] = World.powerNetworks
+		}
+	}
+	for tile, i := range owner {
+		if label := labels[find(i)]; label != 0 {
+			tile.Network = label
+		}
+	}
+}
+
+// PowerNetworks returns the power networks bordering the structure.
+func (s *Structure) PowerNetworks() []int {
+	var networks []int
+	for _, p := range s.AdjacentTiles() {
+		network := World.Power[p.X][p.Y].Network
+		if network == 0 {
+			continue
+		}
+		var found bool
+		for _, n := range networks {
+			if n == network {
+				found = true
+				break
+			}
+		}
+		if !found {
+			networks = append(networks, network)
+		}
+	}
+	return networks
+}
+
+// PowerDrawn returns the power drawn from a power plant to supply the required
+// power with the specified transmission loss.
+func PowerDrawn(required int, loss int) float64 {
+	return float64(required) * 1000 / float64(1000-loss)
+}
+
+// transmissionLoss returns the loss of power carried by a tile.
+func (t *PowerMapTile) transmissionLoss() int {
+	if World.Level.Tiles[0][t.X][t.Y].Structure == StructureHighVoltageLine {
+		return HighVoltagePowerLoss
+	}
+	return PowerLoss
+}
+
+// powerLossBuckets holds the tiles to visit at each loss during a power loss
+// scan. It is reused between scans to avoid allocating for each power plant.
+var powerLossBuckets = make([][]*PowerMapTile, MaxPowerLoss+1)
+
+// ScanPowerLosses calculates the transmission loss of power supplied by a
+// power plant to each tile it reaches along the shortest path.
+func ScanPowerLosses(plant *Structure) {
+	World.powerLossScans++
+	scan := World.powerLossScans
+
+	// Tiles are visited in order of loss, which is bounded by MaxPowerLoss.
+	buckets := powerLossBuckets
+	visit := func(t *PowerMapTile, loss int) {
+		if loss > MaxPowerLoss || (t.lossScan == scan && t.loss <= loss) {
+			return
+		}
+		t.loss, t.lossScan = loss, scan
+		buckets[loss] = append(buckets[loss], t)
+	}
+	for _, p := range plant.AdjacentTiles() {
+		t := World.Power[p.X][p.Y]
+		if t.CarriesPower {
+			visit(t, t.transmissionLoss())
+		}
+	}
+	for loss := range buckets {
+		for i := 0; i < len(buckets[loss]); i++ {
+			t := buckets[loss][i]
+			if t.loss != loss {
+				continue
+			}
+			for _, n := range t.neighbors() {
+				visit(n, loss+n.transmissionLoss())
+			}
+		}
+		for i := range buckets[loss] {
+			buckets[loss][i] = nil
+		}
+		buckets[loss] = buckets[loss][:0]
+	}
+}
+
+// PowerTiles returns the tiles bordering the structure which carry power.
+func (s *Structure) PowerTiles() []*PowerMapTile {
+	var tiles []*PowerMapTile
+	for _, p := range s.AdjacentTiles() {
+		if t := World.Power[p.X][p.Y]; t.CarriesPower {
+			tiles = append(tiles, t)
+		}
+	}
+	return tiles
+}
+
+// TransmissionLoss returns the transmission loss of power supplied via the specified
+// tiles by the power plant last scanned with ScanPowerLosses, or -1 when the
+// power plant does not reach any of the tiles.
+func TransmissionLoss(tiles []*PowerMapTile) int {
+	loss := -1
+	for _, t := range tiles {
+		if t.lossScan == World.powerLossScans && (loss == -1 || t.loss < loss) {
+			loss = t.loss
+		}
 	}
-	return float64(absX + absY)
+	return loss
 }
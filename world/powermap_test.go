@@ -0,0 +1,77 @@
+package world
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// checkPowerNetworks verifies that the power networks updated as tiles were
+// changed match the power networks labeled from scratch.
+func checkPowerNetworks(t *testing.T, step string) {
+	t.Helper()
+
+	width, height := World.Level.width, World.Level.height
+	labels := make([][]int, width)
+	for x := 0; x < width; x++ {
+		labels[x] = make([]int, height)
+		for y := 0; y < height; y++ {
+			labels[x][y] = World.Power[x][y].Network
+		}
+	}
+
+	World.powerNetworksChanged = true
+	UpdatePowerNetworks()
+
+	toFull, toIncremental := make(map[int]int), make(map[int]int)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			tile := World.Power[x][y]
+			incremental, full := labels[x][y], tile.Network
+			if (incremental != 0) != tile.CarriesPower {
+				t.Fatalf("%s: tile %d,%d: carries power %v, network %d", step, x, y, tile.CarriesPower, incremental)
+			}
+			if incremental == 0 {
+				continue
+			}
+			if n, ok := toFull[incremental]; ok && n != full {
+				t.Fatalf("%s: tile %d,%d: network %d is split", step, x, y, incremental)
+			}
+			if n, ok := toIncremental[full]; ok && n != incremental {
+				t.Fatalf("%s: tile %d,%d: networks %d and %d are connected", step, x, y, n, incremental)
+			}
+			toFull[incremental], toIncremental[full] = full, incremental
+		}
+	}
+}
+
+func TestPowerNetworksIncremental(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		t.Run(fmt.Sprint(seed), func(t *testing.T) {
+			newTestCity(t)
+			World.Funds = 100000000
+			UpdatePowerNetworks()
+
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < 300; i++ {
+				x, y := 2+r.Intn(28), 2+r.Intn(28)
+				var c *Command
+				switch r.Intn(4) {
+				case 0:
+					c = &Command{Type: CommandBuildRoad, X: x, Y: y, ToX: x + r.Intn(9) - 4, ToY: y}
+				case 1:
+					c = &Command{Type: CommandBuildPowerLine, X: x, Y: y, ToX: x, ToY: y + r.Intn(9) - 4}
+				case 2:
+					c = &Command{Type: CommandBuildStructure, StructureType: StructureResidentialZone, X: x, Y: y}
+				default:
+					c = &Command{Type: CommandBulldoze, X: x, Y: y}
+				}
+				if !ValidXY(c.ToX, c.ToY) {
+					c.ToX, c.ToY = c.X, c.Y
+				}
+				ApplyCommand(c)
+				checkPowerNetworks(t, fmt.Sprintf("step %d: %s", i, c))
+			}
+		})
+	}
+}
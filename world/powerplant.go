@@ -0,0 +1,56 @@
+package world
+
+// PowerPlantUpkeep is the monthly cost of operating each type of power plant.
+var PowerPlantUpkeep = map[int]int{
+	StructurePowerPlantCoal:    40,
+	StructurePowerPlantSolar:   20,
+	StructurePowerPlantNuclear: 150,
+}
+
+// PowerPlantFuelCosts is the monthly cost of fuel for each type of power plant.
+var PowerPlantFuelCosts = map[int]int{
+	StructurePowerPlantCoal:    60,
+	StructurePowerPlantSolar:   0,
+	StructurePowerPlantNuclear: 50,
+}
+
+// PowerPlantLifespans is the number of years each type of power plant
+// operates before it must be rebuilt.
+var PowerPlantLifespans = map[int]int{
+	StructurePowerPlantCoal:    30,
+	StructurePowerPlantSolar:   20,
+	StructurePowerPlantNuclear: 40,
+}
+
+// minPowerPlantEfficiency is the efficiency of a power plant at the end of its
+// lifespan.
+const minPowerPlantEfficiency = 0.5
+
+// Expired returns whether the power plant has reached the end of its lifespan.
+func (p *PowerPlant) Expired() bool {
+	return p.Age >= PowerPlantLifespans[p.Type]*12
+}
+
+// Efficiency returns the fraction of its original capacity which the power
+// plant supplies. Efficiency decreases with age.
+func (p *PowerPlant) Efficiency() float64 {
+	if p.Expired() {
+		return 0
+	}
+	return 1 - (1-minPowerPlantEfficiency)*float64(p.Age)/float64(PowerPlantLifespans[p.Type]*12)
+}
+
+// Capacity returns the power supplied by the power plant.
+func (p *PowerPlant) Capacity() int {
+	return int(float64(PowerPlantCapacities[p.Type]) * p.Efficiency())
+}
+
+// Expenses returns the monthly cost of operating the power plant, including
+// fuel. Power plants which have reached the end of their lifespan are shut
+// down and cost nothing.
+func (p *PowerPlant) Expenses() int {
+	if p.Expired() {
+		return 0
+	}
+	return PowerPlantUpkeep[p.Type] + PowerPlantFuelCosts[p.Type]
+}
@@ -0,0 +1,212 @@
+package world
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// replayVersion is the version of the replay format. It must be incremented
+// whenever the format changes.
+const replayVersion = 1
+
+// LastReplayFile is the name of the file the current city is recorded to when
+// no replay file is specified.
+const LastReplayFile = "last.replay"
+
+// replaySpeeds are the number of ticks simulated per update while fast-forwarding.
+var replaySpeeds = []int{1, 4, 16, 64}
+
+type savedReplay struct {
+	Version int
+	Seed    int64
+
+	City     []byte     // Starting city, in the save format
+	Commands []*Command // Commands applied, with the tick they were applied at
+	Ticks    int        // Tick at which recording ended
+}
+
+// BeginCity is called whenever a city is generated or loaded. The random
+// number generator is reseeded using the seed and the date, so that the city
+// may be reproduced from a save.
+func BeginCity() {
+	World.Rand = rand.New(rand.NewSource(World.Seed + int64(World.Ticks)))
+	World.Commands = nil
+	clearUndoHistory()
+
+	World.Replaying = false
+	World.ReplaySpeed = 1
+	World.recording = nil
+}
+
+// StartRecording begins recording a replay of the current city. The city is
+// saved as the starting city of the replay.
+func StartRecording() {
+	World.recording = nil
+	if World.Replaying {
+		return
+	}
+
+	city := &bytes.Buffer{}
+	err := SaveCity(city)
+	if err != nil {
+		return
+	}
+	World.recording = &savedReplay{
+		Version: replayVersion,
+		Seed:    World.Seed,
+		City:    city.Bytes(),
+	}
+}
+
+// recordCommand records a command applied at the current tick.
+func recordCommand(c *Command) {
+	if World.recording == nil || World.Replaying {
+		return
+	}
+	recorded := *c
+	recorded.Tick = World.Ticks
+	World.recording.Commands = append(World.recording.Commands, &recorded)
+}
+
+// SaveReplay writes the replay of the current city to w.
+func SaveReplay(w io.Writer) error {
+	if World.recording == nil {
+		return fmt.Errorf("no replay recorded")
+	}
+	World.recording.Ticks = World.Ticks
+
+	gz := gzip.NewWriter(w)
+	err := json.NewEncoder(gz).Encode(World.recording)
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// LoadReplay replaces the current city with the starting city of the replay
+// read from r and queues the recorded commands.
+func LoadReplay(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	replay := &savedReplay{}
+	err = json.NewDecoder(gz).Decode(replay)
+	if err != nil {
+		return err
+	}
+
+	if replay.Version != replayVersion {
+		return fmt.Errorf("unsupported replay version: %d", replay.Version)
+	}
+
+	city, err := readCity(bytes.NewReader(replay.City))
+	if err != nil {
+		return err
+	}
+	if replay.Ticks < city.Ticks {
+		return fmt.Errorf("invalid replay: ends at tick %d before it starts at tick %d", replay.Ticks, city.Ticks)
+	}
+
+	World.Seed = replay.Seed
+	err = LoadCity(bytes.NewReader(replay.City))
+	if err != nil {
+		return err
+	}
+
+	World.Commands = replay.Commands
+	World.Replaying = true
+	World.ReplayEnd = replay.Ticks
+	World.Paused = false
+	return nil
+}
+
+// SaveReplayFile saves the replay of the current city to the specified file.
+func SaveReplayFile(filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	err = SaveReplay(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadReplayFile loads a replay from the specified file.
+func LoadReplayFile(filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return LoadReplay(f)
+}
+
+// saveRecording saves the replay of the current city to the replay file.
+func saveRecording() error {
+	if World.recording == nil || World.Replaying {
+		return nil
+	}
+
+	filePath := World.RecordFile
+	if filePath == "" {
+		dir, err := SaveDir()
+		if err != nil {
+			return err
+		}
+		filePath = filepath.Join(dir, LastReplayFile)
+	}
+	return SaveReplayFile(filePath)
+}
+
+// UpdateReplay pauses the game once all recorded commands have been applied
+// and the end of the replay has been reached.
+func UpdateReplay() {
+	if !World.Replaying || World.Paused || len(World.Commands) > 0 || World.Ticks < World.ReplayEnd {
+		return
+	}
+	World.Paused = true
+	World.ReplaySpeed = 1
+	ShowMessage("Replay finished", 5)
+}
+
+// ToggleReplayPaused pauses or resumes a replay.
+func ToggleReplayPaused() {
+	if !World.Replaying {
+		return
+	}
+	World.Paused = !World.Paused
+	if World.Paused {
+		ShowMessage("Replay paused", 3)
+	} else {
+		ShowMessage("Replay resumed", 3)
+	}
+}
+
+// CycleReplaySpeed cycles between the available fast-forward speeds.
+func CycleReplaySpeed() {
+	if !World.Replaying {
+		return
+	}
+	speed := replaySpeeds[0]
+	for i, s := range replaySpeeds {
+		if s == World.ReplaySpeed && i < len(replaySpeeds)-1 {
+			speed = replaySpeeds[i+1]
+		}
+	}
+	World.ReplaySpeed = speed
+	ShowMessage(fmt.Sprintf("Replay speed %dx", speed), 3)
+}
@@ -0,0 +1,74 @@
+package world
+
+import (
+	"bytes"
+	"testing"
+)
+
+// recordTestCity records a replay of the test city which ends at the specified tick.
+func recordTestCity(t *testing.T, ticks int) *bytes.Buffer {
+	newTestCity(t)
+	World.Ticks = 100
+	StartRecording()
+	World.Ticks = ticks
+
+	buf := &bytes.Buffer{}
+	err := SaveReplay(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestLoadCityDoesNotRecord(t *testing.T) {
+	newTestCity(t)
+	city := saveTestCity(t)
+
+	err := LoadCity(encodeCity(t, city))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = SaveReplay(&bytes.Buffer{})
+	if err == nil {
+		t.Error("expected no replay to be recorded when loading a city")
+	}
+}
+
+func TestLoadReplayInvalidEnd(t *testing.T) {
+	buf := recordTestCity(t, 50)
+
+	newTestCity(t)
+	err := LoadReplay(buf)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if World.Replaying || World.Ticks != 0 {
+		t.Error("city was modified by invalid replay")
+	}
+}
+
+func TestUpdateReplay(t *testing.T) {
+	buf := recordTestCity(t, 200)
+
+	err := LoadReplay(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !World.Replaying || World.Ticks != 100 || World.ReplayEnd != 200 {
+		t.Fatalf("expected replay from tick 100 to 200, got replaying %v from tick %d to %d", World.Replaying, World.Ticks, World.ReplayEnd)
+	}
+
+	World.Ticks = 199
+	UpdateReplay()
+	if World.Paused {
+		t.Fatal("replay paused before its end")
+	}
+
+	// Fast-forwarding may advance past the end of the replay.
+	World.Ticks = 203
+	UpdateReplay()
+	if !World.Paused {
+		t.Error("replay did not pause after its end")
+	}
+	World.Paused, World.Replaying = false, false
+}
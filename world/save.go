@@ -0,0 +1,406 @@
+package world
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// saveVersion is the version of the city save format. It must be incremented
+// whenever the format changes.
+const saveVersion = 11
+
+// QuickSaveFile is the name of the file used when saving and loading via hotkey.
+const QuickSaveFile = "quicksave.sav"
+
+var ErrUnsupportedSaveVersion = errors.New("unsupported save version")
+
+type savedLayer struct {
+	Sprites            []uint32 // Tile GIDs, indexed by x*height+y
+	EnvironmentSprites []uint32
+}
+
+type savedCity struct {
+	Version int
+	Size    int `json:",omitempty"` // Width and height of square maps before version 4
+	Width   int
+	Height  int
+	Seed    int64
+
+	Ticks int
+	Funds int
+
+	TaxR float64
+	TaxC float64
+	TaxI float64
+
+	PoliceFunding float64
+	FireFunding   float64
+	PowerPriority int
+
+	Zones       []*Zone
+	PowerPlants []*PowerPlant
+	Fires       []*Fire
+
+	Layers     []*savedLayer
+	Structures []int // Structure types of ground tiles, indexed by x*height+y
+	Heights    []int // Elevation of ground tiles, indexed by x*height+y
+	Power      []bool
+	Pollution  []float64
+}
+
+// SaveCity writes the current city to w.
+func SaveCity(w io.Writer) error {
+	width, height := World.Level.width, World.Level.height
+	size := width * height
+
+	city := &savedCity{
+		Version:       saveVersion,
+		Width:         width,
+		Height:        height,
+		Seed:          World.Seed,
+		Ticks:         World.Ticks,
+		Funds:         World.Funds,
+		TaxR:          World.TaxR,
+		TaxC:          World.TaxC,
+		TaxI:          World.TaxI,
+		PoliceFunding: World.PoliceFunding,
+		FireFunding:   World.FireFunding,
+		PowerPriority: World.PowerPriority,
+		Zones:         World.Zones,
+		PowerPlants:   World.PowerPlants,
+		Fires:         World.Fires,
+		Structures:    make([]int, size),
+		Heights:       make([]int, size),
+		Power:         make([]bool, size),
+		Pollution:     make([]float64, size),
+	}
+
+	for i := range World.Level.Tiles {
+		layer := &savedLayer{
+			Sprites:            make([]uint32, size),
+			EnvironmentSprites: make([]uint32, size),
+		}
+		for x := range World.Level.Tiles[i] {
+			for y, tile := range World.Level.Tiles[i][x] {
+				layer.Sprites[x*height+y] = tile.Sprite
+				layer.EnvironmentSprites[x*height+y] = tile.EnvironmentSprite
+				if i == 0 {
+					city.Structures[x*height+y] = tile.Structure
+					city.Heights[x*height+y] = tile.Height
+				}
+			}
+		}
+		city.Layers = append(city.Layers, layer)
+	}
+
+	for x := range World.Power {
+		for y, t := range World.Power[x] {
+			city.Power[x*height+y] = t.CarriesPower
+			city.Pollution[x*height+y] = World.Pollution[x][y]
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	err := json.NewEncoder(gz).Encode(city)
+	if err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// readCity decodes a city in the save format without validating it.
+func readCity(r io.Reader) (*savedCity, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	city := &savedCity{}
+	err = json.NewDecoder(gz).Decode(city)
+	if err != nil {
+		return nil, err
+	}
+	return city, nil
+}
+
+// LoadCity replaces the current city with the city read from r.
+func LoadCity(r io.Reader) error {
+	city, err := readCity(r)
+	if err != nil {
+		return err
+	}
+
+	if city.Version < 1 || city.Version > saveVersion {
+		return fmt.Errorf("%w: %d", ErrUnsupportedSaveVersion, city.Version)
+	}
+
+	if city.Version < 4 {
+		city.Width, city.Height = city.Size, city.Size
+	}
+	width, height := city.Width, city.Height
+	if width < MinMapSize || height < MinMapSize || width > MaxMapSize || height > MaxMapSize {
+		return fmt.Errorf("unsupported map size: %dx%d", width, height)
+	}
+	size := width * height
+	if len(city.Power) != size {
+		return errors.New("invalid power map")
+	}
+	for _, layer := range city.Layers {
+		if len(layer.Sprites) != size || len(layer.EnvironmentSprites) != size {
+			return errors.New("invalid map layer")
+		}
+	}
+
+	level := NewLevel(width, height)
+	for i, layer := range city.Layers {
+		for i > len(level.Tiles)-1 {
+			level.AddLayer()
+		}
+		for x := range level.Tiles[i] {
+			for y, tile := range level.Tiles[i][x] {
+				tile.Sprite = layer.Sprites[x*height+y]
+				tile.EnvironmentSprite = layer.EnvironmentSprites[x*height+y]
+			}
+		}
+	}
+
+	if city.Version == 1 {
+		// Structure types were not saved. Identify roads by their sprites.
+		city.Structures = make([]int, size)
+		for x := range level.Tiles[0] {
+			for y, tile := range level.Tiles[0][x] {
+				if tile.Sprite == TileGID(RoadTile) && level.Tiles[1][x][y].Sprite == 0 {
+					city.Structures[x*height+y] = StructureRoad
+				}
+			}
+		}
+	} else if len(city.Structures) != size {
+		return errors.New("invalid structure map")
+	}
+	if city.Version < 5 {
+		// Terrain elevation was not saved. All terrain was flat.
+		city.Heights = make([]int, size)
+	} else if len(city.Heights) != size {
+		return errors.New("invalid height map")
+	}
+	for x := range level.Tiles[0] {
+		for y, tile := range level.Tiles[0][x] {
+			tile.Structure = city.Structures[x*height+y]
+			if !isTileStructure(tile.Structure) {
+				return errors.New("invalid structure map")
+			}
+			tile.Height = city.Heights[x*height+y]
+			if tile.Height < 0 || tile.Height > MaxElevation {
+				return errors.New("invalid height map")
+			}
+		}
+	}
+
+	if city.Ticks < 0 {
+		return errors.New("invalid ticks")
+	}
+	for _, tax := range []float64{city.TaxR, city.TaxC, city.TaxI} {
+		if tax < 0 || tax > 1 {
+			return errors.New("invalid tax rate")
+		}
+	}
+	if city.Version < 6 {
+		// Police funding was not saved. Police were fully funded.
+		city.PoliceFunding = startingFunding
+	} else if city.PoliceFunding < 0 || city.PoliceFunding > 1 {
+		return errors.New("invalid police funding")
+	}
+	if city.Version < 11 {
+		// Fire station funding was not saved. Fire stations were fully funded.
+		city.FireFunding = startingFunding
+	} else if city.FireFunding < 0 || city.FireFunding > 1 {
+		return errors.New("invalid fire funding")
+	}
+	if city.PowerPriority != 0 && !IsZone(city.PowerPriority) {
+		return errors.New("invalid power priority")
+	}
+	for _, zone := range city.Zones {
+		if zone == nil || !IsZone(zone.Type) {
+			return errors.New("invalid zone")
+		} else if zone.Population < 0 || zone.Population > MaxZonePopulation {
+			return errors.New("invalid zone population")
+		}
+		err = validateStructureLocation(zone.Type, zone.X, zone.Y, width, height)
+		if err != nil {
+			return err
+		}
+	}
+	for _, plant := range city.PowerPlants {
+		if plant == nil || !IsPowerPlant(plant.Type) {
+			return errors.New("invalid power plant")
+		}
+		err = validateStructureLocation(plant.Type, plant.X, plant.Y, width, height)
+		if err != nil {
+			return err
+		}
+		if plant.Age < 0 {
+			return errors.New("invalid power plant age")
+		}
+	}
+
+	power := newPowerMap(width, height)
+	for x := range power {
+		for y, t := range power[x] {
+			t.CarriesPower = city.Power[x*height+y]
+		}
+	}
+
+	if city.Version < 8 {
+		// Pollution was not saved.
+		city.Pollution = make([]float64, size)
+	} else if len(city.Pollution) != size {
+		return errors.New("invalid pollution map")
+	}
+	pollution := newPollutionMap(width, height)
+	for x := range pollution {
+		for y := range pollution[x] {
+			v := city.Pollution[x*height+y]
+			if v < 0 || v > MaxPollution {
+				return errors.New("invalid pollution map")
+			}
+			pollution[x][y] = v
+		}
+	}
+
+	World.Ticks = city.Ticks
+	if city.Seed != 0 {
+		World.Seed = city.Seed
+	}
+	World.Funds = city.Funds
+	World.TaxR, World.TaxC, World.TaxI = city.TaxR, city.TaxC, city.TaxI
+	World.PoliceFunding = city.PoliceFunding
+	World.FireFunding = city.FireFunding
+	World.PowerPriority = city.PowerPriority
+
+	setCity(level, power, pollution, city.Zones, city.PowerPlants, city.Fires)
+	return nil
+}
+
+// validateStructureLocation returns an error when a structure anchored at x,y
+// does not fit on a map of the specified size.
+func validateStructureLocation(structureType int, x int, y int, width int, height int) error {
+	w, h, err := structureSize(structureType)
+	if err != nil {
+		return err
+	}
+	if x-(w-1) < 0 || y-(h-1) < 0 || x >= width || y >= height {
+		return fmt.Errorf("invalid location of %s at %d,%d", strings.ToLower(StructureTooltips[structureType]), x, y)
+	}
+	return nil
+}
+
+// setCity replaces the current city's map, structures, pollution and fires.
+func setCity(level *GameLevel, power PowerMap, pollution PollutionMap, zones []*Zone, powerPlants []*PowerPlant, fires []*Fire) {
+	World.Level = level
+	World.Power = power
+	World.powerNetworksChanged = true
+	World.Pollution = pollution
+	World.Zones = zones
+	World.PowerPlants = powerPlants
+	World.Fires = nil
+	rebuildStructures()
+
+	// Fires which are not burning a structure are ignored.
+	for _, fire := range fires {
+		s := StructureAt(fire.X, fire.Y)
+		if s != nil && s.X == fire.X && s.Y == fire.Y && s.Type != StructureRubble && FireAt(s) == nil {
+			World.Fires = append(World.Fires, fire)
+		}
+	}
+
+	World.BuildDragX, World.BuildDragY = -1, -1
+	World.LastBuildX, World.LastBuildY = -1, -1
+
+	ResetPowerOuts()
+	World.PowerUpdated = true
+	World.HUDUpdated = true
+
+	// The state of the random number generator is not saved. It is derived
+	// from the seed and the date so that loading is deterministic.
+	BeginCity()
+}
+
+// SaveDir returns the directory where cities are saved, creating it if necessary.
+func SaveDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(configDir, "citylimits")
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// SaveCityFile saves the current city to the specified file.
+func SaveCityFile(filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	err = SaveCity(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadCityFile loads a city from the specified file. Tiled maps (.tmx) are
+// imported via ImportTMXFile.
+func LoadCityFile(filePath string) error {
+	if strings.EqualFold(filepath.Ext(filePath), ".tmx") {
+		return ImportTMXFile(filePath)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return LoadCity(f)
+}
+
+// QuickSave saves the current city to the quick save file.
+func QuickSave() {
+	dir, err := SaveDir()
+	if err == nil {
+		err = SaveCityFile(filepath.Join(dir, QuickSaveFile))
+	}
+	if err != nil {
+		ShowMessage(fmt.Sprintf("Failed to save city: %s", err), 5)
+		return
+	}
+	ShowMessage("Saved city", 3)
+}
+
+// QuickLoad loads the city in the quick save file.
+func QuickLoad() {
+	dir, err := SaveDir()
+	if err == nil {
+		err = LoadCityFile(filepath.Join(dir, QuickSaveFile))
+	}
+	if err != nil {
+		ShowMessage(fmt.Sprintf("Failed to load city: %s", err), 5)
+		return
+	}
+	StartRecording()
+	ShowMessage("Loaded city", 3)
+}
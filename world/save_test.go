@@ -0,0 +1,198 @@
+package world
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// newTestCity resets the world to a flat 32x32 city with a road, a zone and a
+// power plant.
+func newTestCity(t *testing.T) {
+	World.MapSeed = 1
+	World.MapWidth, World.MapHeight = 32, 32
+	Reset()
+	World.Funds = 100000
+
+	for x := range World.Level.Tiles[0] {
+		for _, tile := range World.Level.Tiles[0][x] {
+			tile.EnvironmentSprite = TileGID(DirtTile)
+		}
+	}
+	World.Level.Tiles[0][20][20].Height = 1
+
+	for _, c := range []*Command{
+		{Type: CommandBuildRoad, X: 2, Y: 10, ToX: 28, ToY: 10},
+		{Type: CommandBuildStructure, StructureType: StructureResidentialZone, X: 6, Y: 8},
+		{Type: CommandBuildStructure, StructureType: StructurePowerPlantCoal, X: 14, Y: 15},
+	} {
+		err := ApplyCommand(c)
+		if err != nil {
+			t.Fatalf("%s: %s", c, err)
+		}
+	}
+	World.Zones[0].Population = 3
+}
+
+// encodeCity returns a city in the save format.
+func encodeCity(t *testing.T, city *savedCity) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	err := json.NewEncoder(gz).Encode(city)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = gz.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+// saveTestCity returns the current city as it is saved.
+func saveTestCity(t *testing.T) *savedCity {
+	buf := &bytes.Buffer{}
+	err := SaveCity(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	city := &savedCity{}
+	err = json.NewDecoder(gz).Decode(city)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return city
+}
+
+func TestSaveLoadCity(t *testing.T) {
+	newTestCity(t)
+	World.Ticks = 1234
+	World.TaxR, World.PoliceFunding, World.FireFunding = 0.2, 0.5, 0.7
+
+	level, funds := World.Level, World.Funds
+	buf := &bytes.Buffer{}
+	err := SaveCity(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Reset()
+	err = LoadCity(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if World.Level.width != level.width || World.Level.height != level.height || len(World.Level.Tiles) != len(level.Tiles) {
+		t.Fatalf("expected %dx%d map with %d layers, got %dx%d with %d layers", level.width, level.height, len(level.Tiles), World.Level.width, World.Level.height, len(World.Level.Tiles))
+	}
+	for i := range level.Tiles {
+		for x := range level.Tiles[i] {
+			for y, tile := range level.Tiles[i][x] {
+				expected, got := *tile, *World.Level.Tiles[i][x][y]
+				expected.HoverSprite, got.HoverSprite = 0, 0
+				if got != expected {
+					t.Fatalf("layer %d tile %d,%d: expected %+v, got %+v", i, x, y, expected, got)
+				}
+			}
+		}
+	}
+	if !World.Power[10][10].CarriesPower || World.Power[0][0].CarriesPower {
+		t.Errorf("power map was not restored")
+	}
+	if World.Ticks != 1234 || World.Funds != funds || World.TaxR != 0.2 || World.PoliceFunding != 0.5 || World.FireFunding != 0.7 {
+		t.Errorf("city statistics were not restored")
+	}
+	if len(World.Zones) != 1 || World.Zones[0].Population != 3 || len(World.PowerPlants) != 1 {
+		t.Fatalf("expected 1 zone and 1 power plant, got %d and %d", len(World.Zones), len(World.PowerPlants))
+	}
+	if s := StructureAt(6, 8); s == nil || s.Zone != World.Zones[0] {
+		t.Errorf("zone structure was not restored")
+	}
+	if s := StructureAt(14, 15); s == nil || s.PowerPlant != World.PowerPlants[0] {
+		t.Errorf("power plant structure was not restored")
+	}
+}
+
+func TestLoadCityVersion1(t *testing.T) {
+	newTestCity(t)
+	city := saveTestCity(t)
+
+	// Version 1 saves only included square maps, without structure types or
+	// elevation.
+	city.Version = 1
+	city.Size, city.Width, city.Height = city.Width, 0, 0
+	city.Structures, city.Heights = nil, nil
+
+	err := LoadCity(encodeCity(t, city))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if World.Level.width != 32 || World.Level.height != 32 {
+		t.Fatalf("expected 32x32 map, got %dx%d", World.Level.width, World.Level.height)
+	}
+	for x := range World.Level.Tiles[0] {
+		for y, tile := range World.Level.Tiles[0][x] {
+			road := tile.Sprite == TileGID(RoadTile) && World.Level.Tiles[1][x][y].Sprite == 0
+			if road != (tile.Structure == StructureRoad) {
+				t.Fatalf("tile %d,%d: road %v, structure %d", x, y, road, tile.Structure)
+			}
+			if tile.Height != 0 {
+				t.Fatalf("tile %d,%d: expected height 0, got %d", x, y, tile.Height)
+			}
+		}
+	}
+	if World.Level.Tiles[0][10][10].Structure != StructureRoad {
+		t.Errorf("road was not identified by its sprite")
+	}
+}
+
+func TestLoadCityInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(city *savedCity)
+	}{
+		{"version too new", func(city *savedCity) { city.Version = saveVersion + 1 }},
+		{"version zero", func(city *savedCity) { city.Version = 0 }},
+		{"too small", func(city *savedCity) { city.Width = MinMapSize - 1 }},
+		{"too large", func(city *savedCity) { city.Height = MaxMapSize + 1 }},
+		{"size mismatch", func(city *savedCity) { city.Width = 33 }},
+		{"negative height", func(city *savedCity) { city.Heights[5] = -1 }},
+		{"height too high", func(city *savedCity) { city.Heights[5] = MaxElevation + 1 }},
+		{"height map size", func(city *savedCity) { city.Heights = city.Heights[1:] }},
+		{"zone outside map", func(city *savedCity) { city.Zones[0].X = 500 }},
+		{"invalid zone type", func(city *savedCity) { city.Zones[0].Type = StructureRoad }},
+		{"power plant outside map", func(city *savedCity) { city.PowerPlants[0].Y = 0 }},
+		{"negative ticks", func(city *savedCity) { city.Ticks = -1 }},
+		{"toggle structure type", func(city *savedCity) { city.Structures[5] = StructureToggleHelp }},
+		{"unknown structure type", func(city *savedCity) { city.Structures[5] = 99 }},
+		{"zone population", func(city *savedCity) { city.Zones[0].Population = MaxZonePopulation + 1 }},
+		{"negative tax rate", func(city *savedCity) { city.TaxC = -0.1 }},
+		{"tax rate too high", func(city *savedCity) { city.TaxI = 1.5 }},
+		{"police funding", func(city *savedCity) { city.PoliceFunding = 2 }},
+		{"fire funding", func(city *savedCity) { city.FireFunding = -1 }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestCity(t)
+			city := saveTestCity(t)
+			test.modify(city)
+
+			err := LoadCity(encodeCity(t, city))
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if test.name == "version too new" && !errors.Is(err, ErrUnsupportedSaveVersion) {
+				t.Errorf("expected ErrUnsupportedSaveVersion, got %s", err)
+			}
+			if World.Level.width != 32 || len(World.Zones) != 1 {
+				t.Errorf("city was modified by invalid save")
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package world
 
-import "code.rocketnine.space/tslocum/gohan"
+import (
+	"image"
+	"math"
+)
 
 const (
 	StructureToggleHelp = iota + 1
@@ -23,6 +26,12 @@ const (
 	StructurePowerPlantCoal
 	StructurePowerPlantSolar
 	StructurePowerPlantNuclear
+	StructureRaiseTerrain
+	StructureLowerTerrain
+	StructureFireStation
+	StructureRubble
+	StructurePowerLine
+	StructureHighVoltageLine
 )
 
 var StructureFilePaths = map[int]string{
@@ -44,12 +53,274 @@ var StructureFilePaths = map[int]string{
 	StructurePowerPlantCoal:    "map/power_coal.tmx",
 	StructurePowerPlantSolar:   "map/power_solar.tmx",
 	StructurePowerPlantNuclear: "map/power_nuclear.tmx",
+	StructureRaiseTerrain:      "map/raise_terrain.tmx",
+	StructureLowerTerrain:      "map/lower_terrain.tmx",
+	StructureFireStation:       "map/firestation.tmx",
+	StructureRubble:            "map/rubble.tmx",
+	StructurePowerLine:         "map/power_line.tmx",
+	StructureHighVoltageLine:   "map/high_voltage_line.tmx",
 }
 
+// Structure is a placed structure. Structures are anchored at their
+// bottom-right tile.
 type Structure struct {
-	Type int
-	X, Y int
+	Type          int
+	X, Y          int
+	Width, Height int // Size of the structure in tiles
+
+	Zone       *Zone       // Simulation record of zones
+	PowerPlant *PowerPlant // Simulation record of power plants
+}
+
+// Rect returns the tiles occupied by the structure.
+func (s *Structure) Rect() image.Rectangle {
+	return image.Rect(s.X-s.Width+1, s.Y-s.Height+1, s.X+1, s.Y+1)
+}
+
+// Contains returns whether the structure occupies the specified tile.
+func (s *Structure) Contains(x int, y int) bool {
+	return image.Pt(x, y).In(s.Rect())
+}
+
+// Distance returns the distance between the specified tile and the nearest
+// tile occupied by the structure.
+func (s *Structure) Distance(x int, y int) float64 {
+	r := s.Rect()
+	var dx, dy int
+	if x < r.Min.X {
+		dx = r.Min.X - x
+	} else if x >= r.Max.X {
+		dx = x - (r.Max.X - 1)
+	}
+	if y < r.Min.Y {
+		dy = r.Min.Y - y
+	} else if y >= r.Max.Y {
+		dy = y - (r.Max.Y - 1)
+	}
+	return math.Hypot(float64(dx), float64(dy))
+}
+
+// AdjacentTiles returns the tiles bordering each side of the structure.
+// Tiles outside of the level are not included.
+func (s *Structure) AdjacentTiles() []image.Point {
+	r := s.Rect()
+	var tiles []image.Point
+	add := func(x int, y int) {
+		if ValidXY(x, y) {
+			tiles = append(tiles, image.Pt(x, y))
+		}
+	}
+	for x := r.Min.X; x < r.Max.X; x++ {
+		add(x, r.Min.Y-1)
+		add(x, r.Max.Y)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		add(r.Min.X-1, y)
+		add(r.Max.X, y)
+	}
+	return tiles
+}
+
+// StructureAt returns the structure occupying the specified tile, or nil when
+// the tile is not occupied by a structure. Roads are not structures.
+func StructureAt(x int, y int) *Structure {
+	if !ValidXY(x, y) || World.structureMap == nil {
+		return nil
+	}
+	return World.structureMap[x][y]
+}
+
+// StructuresInRect returns the structures occupying any tile within the
+// specified rectangle of tiles.
+func StructuresInRect(r image.Rectangle) []*Structure {
+	r = r.Intersect(image.Rect(0, 0, World.Level.width, World.Level.height))
+	if r.Empty() || World.structureMap == nil {
+		return nil
+	}
+
+	var structures []*Structure
+	found := make(map[*Structure]bool)
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			s := World.structureMap[x][y]
+			if s != nil && !found[s] {
+				structures = append(structures, s)
+				found[s] = true
+			}
+		}
+	}
+	return structures
+}
+
+// StructuresWithin returns the structures occupying any tile within the
+// specified distance of a tile.
+func StructuresWithin(x int, y int, radius float64) []*Structure {
+	r := int(math.Ceil(radius))
+	var structures []*Structure
+	for _, s := range StructuresInRect(image.Rect(x-r, y-r, x+r+1, y+r+1)) {
+		if s.Distance(x, y) <= radius {
+			structures = append(structures, s)
+		}
+	}
+	return structures
+}
+
+// NeighborsOf returns the structures bordering the specified structure.
+func NeighborsOf(s *Structure) []*Structure {
+	var neighbors []*Structure
+	found := map[*Structure]bool{s: true}
+	for _, p := range s.AdjacentTiles() {
+		n := StructureAt(p.X, p.Y)
+		if n != nil && !found[n] {
+			neighbors = append(neighbors, n)
+			found[n] = true
+		}
+	}
+	return neighbors
+}
+
+// serviceCoverage returns how effectively structures of the specified type
+// serve the specified tile, between 0 and 1. Coverage is strongest next to a
+// structure and falls to zero at the specified radius.
+func serviceCoverage(structureType int, radius float64, x int, y int) float64 {
+	var coverage float64
+	for _, s := range StructuresWithin(x, y, radius) {
+		if s.Type != structureType {
+			continue
+		}
+		c := 1 - s.Distance(x, y)/radius
+		if c > coverage {
+			coverage = c
+		}
+	}
+	return coverage
+}
+
+// isRegisteredStructure returns whether placed structures of the specified
+// type are tracked in the structure registry. Roads and power lines are
+// tracked per tile.
+func isRegisteredStructure(structureType int) bool {
+	return structureType != 0 && structureType != StructureRoad && !IsPowerLine(structureType) && structureType != StructureBulldozer && !IsTerraformTool(structureType)
+}
+
+// isTileStructure returns whether a structure type may occupy a ground tile.
+// Tiles without a structure have structure type 0.
+func isTileStructure(structureType int) bool {
+	return structureType == 0 || (StructureFilePaths[structureType] != "" && structureType != StructureBulldozer && !IsTerraformTool(structureType))
+}
+
+func newStructureMap(width int, height int) [][]*Structure {
+	m := make([][]*Structure, width)
+	for x := 0; x < width; x++ {
+		m[x] = make([]*Structure, height)
+	}
+	return m
+}
+
+// registerStructure adds a placed structure to the structure registry.
+func registerStructure(s *Structure) {
+	World.Structures = append(World.Structures, s)
+	r := s.Rect()
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			if ValidXY(x, y) {
+				World.structureMap[x][y] = s
+			}
+		}
+	}
+}
+
+// unregisterStructure removes a structure from the structure registry, along
+// with its simulation record.
+func unregisterStructure(s *Structure) {
+	for i, structure := range World.Structures {
+		if structure == s {
+			World.Structures = append(World.Structures[:i], World.Structures[i+1:]...)
+			break
+		}
+	}
+	r := s.Rect()
+	for x := r.Min.X; x < r.Max.X; x++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			if ValidXY(x, y) && World.structureMap[x][y] == s {
+				World.structureMap[x][y] = nil
+			}
+		}
+	}
+	if World.SelectedStructure == s {
+		World.SelectedStructure = nil
+		World.HUDUpdated = true
+	}
+
+	if s.Zone != nil {
+		for i, zone := range World.Zones {
+			if zone == s.Zone {
+				World.Zones = append(World.Zones[:i], World.Zones[i+1:]...)
+				break
+			}
+		}
+	}
+	if fire := FireAt(s); fire != nil {
+		ExtinguishFire(fire)
+	}
+	if s.PowerPlant != nil {
+		for i, plant := range World.PowerPlants {
+			if plant == s.PowerPlant {
+				World.PowerPlants = append(World.PowerPlants[:i], World.PowerPlants[i+1:]...)
+				World.PowerUpdated = true
+				break
+			}
+		}
+	}
+}
+
+// rebuildStructures rebuilds the structure registry from the simulation
+// records of zones and power plants. Other structures are identified by the
+// structure types of ground tiles.
+func rebuildStructures() {
+	World.Structures = nil
+	World.structureMap = newStructureMap(World.Level.width, World.Level.height)
+	World.SelectedStructure = nil
+
+	register := func(structureType int, x int, y int) *Structure {
+		w, h, err := structureSize(structureType)
+		if err != nil {
+			return nil
+		}
+		s := &Structure{
+			Type:   structureType,
+			X:      x,
+			Y:      y,
+			Width:  w,
+			Height: h,
+		}
+		registerStructure(s)
+		return s
+	}
+	for _, zone := range World.Zones {
+		if s := register(zone.Type, zone.X, zone.Y); s != nil {
+			s.Zone = zone
+		}
+	}
+	for _, plant := range World.PowerPlants {
+		if s := register(plant.Type, plant.X, plant.Y); s != nil {
+			s.PowerPlant = plant
+		}
+	}
 
-	Entity   gohan.Entity
-	Children []gohan.Entity
+	// Tiles are scanned in order, so the first unregistered tile found of
+	// each structure is its top-left tile.
+	for x := 0; x < World.Level.width; x++ {
+		for y := 0; y < World.Level.height; y++ {
+			structureType := World.Level.Tiles[0][x][y].Structure
+			if World.structureMap[x][y] != nil || !isRegisteredStructure(structureType) {
+				continue
+			}
+			w, h, err := structureSize(structureType)
+			if err != nil {
+				continue
+			}
+			register(structureType, x+w-1, y+h-1)
+		}
+	}
 }
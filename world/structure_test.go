@@ -0,0 +1,190 @@
+package world
+
+import (
+	"image"
+	"testing"
+)
+
+// newStructureTestCity builds the test city with a zone in the top-left corner
+// of the map and a police station bordering the zone at 6,8.
+func newStructureTestCity(t *testing.T) (zone, plant, corner, station *Structure) {
+	newTestCity(t)
+	for _, c := range []*Command{
+		{Type: CommandBuildStructure, StructureType: StructureCommercialZone, X: 1, Y: 1},
+		{Type: CommandBuildStructure, StructureType: StructurePoliceStation, X: 4, Y: 8},
+	} {
+		err := ApplyCommand(c)
+		if err != nil {
+			t.Fatalf("%s: %s", c, err)
+		}
+	}
+	zone, plant, corner, station = StructureAt(6, 8), StructureAt(14, 15), StructureAt(1, 1), StructureAt(4, 8)
+	if zone == nil || plant == nil || corner == nil || station == nil {
+		t.Fatal("structures were not built")
+	}
+	if zone.Rect() != image.Rect(5, 7, 7, 9) || plant.Rect() != image.Rect(10, 11, 15, 16) || corner.Rect() != image.Rect(0, 0, 2, 2) {
+		t.Fatalf("unexpected footprints %s, %s and %s", zone.Rect(), plant.Rect(), corner.Rect())
+	}
+	return zone, plant, corner, station
+}
+
+// sameStructures returns whether two lists contain the same structures.
+func sameStructures(a []*Structure, b []*Structure) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	found := make(map[*Structure]bool)
+	for _, s := range a {
+		found[s] = true
+	}
+	for _, s := range b {
+		if !found[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// structureRects returns the footprints of structures, for error messages.
+func structureRects(structures ...*Structure) []image.Rectangle {
+	var rects []image.Rectangle
+	for _, s := range structures {
+		if s == nil {
+			rects = append(rects, image.Rectangle{})
+			continue
+		}
+		rects = append(rects, s.Rect())
+	}
+	return rects
+}
+
+func TestStructureAt(t *testing.T) {
+	zone, plant, corner, _ := newStructureTestCity(t)
+
+	tests := []struct {
+		x, y     int
+		expected *Structure
+	}{
+		{6, 8, zone},
+		{5, 7, zone},
+		{10, 11, plant},
+		{12, 13, plant},
+		{14, 15, plant},
+		{15, 15, nil},
+		{14, 16, nil},
+		{0, 0, corner},
+		{20, 10, nil}, // Roads are not structures.
+		{-1, 0, nil},
+		{0, -1, nil},
+		{32, 5, nil},
+		{5, 32, nil},
+	}
+	for _, test := range tests {
+		if s := StructureAt(test.x, test.y); s != test.expected {
+			t.Errorf("%d,%d: expected %v, got %v", test.x, test.y, structureRects(test.expected), structureRects(s))
+		}
+	}
+}
+
+func TestStructuresInRect(t *testing.T) {
+	zone, plant, corner, station := newStructureTestCity(t)
+
+	tests := []struct {
+		name     string
+		r        image.Rectangle
+		expected []*Structure
+	}{
+		{"single tile", image.Rect(12, 12, 13, 13), []*Structure{plant}},
+		{"part of footprint", image.Rect(14, 15, 20, 20), []*Structure{plant}},
+		{"several structures", image.Rect(4, 7, 11, 12), []*Structure{zone, plant, station}},
+		{"adjacent to footprint", image.Rect(15, 11, 20, 16), nil},
+		{"map edge", image.Rect(-5, -5, 1, 1), []*Structure{corner}},
+		{"outside map", image.Rect(32, 32, 40, 40), nil},
+		{"empty", image.Rect(6, 8, 6, 8), nil},
+		{"entire map", image.Rect(0, 0, 32, 32), []*Structure{zone, plant, corner, station}},
+	}
+	for _, test := range tests {
+		if s := StructuresInRect(test.r); !sameStructures(s, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, structureRects(test.expected...), structureRects(s...))
+		}
+	}
+}
+
+func TestStructuresWithin(t *testing.T) {
+	zone, plant, corner, _ := newStructureTestCity(t)
+
+	tests := []struct {
+		name     string
+		x, y     int
+		radius   float64
+		expected []*Structure
+	}{
+		{"inside", 12, 13, 0, []*Structure{plant}},
+		{"beside", 15, 13, 1, []*Structure{plant}},
+		{"beside out of range", 16, 13, 1, nil},
+		{"diagonal", 15, 16, 1.5, []*Structure{plant}},
+		{"diagonal out of range", 15, 16, 1, nil},
+		{"between", 7, 9, 2, []*Structure{zone}},
+		{"outside map", -2, -2, 3, []*Structure{corner}},
+		{"map edge", 0, 3, 2, []*Structure{corner}},
+	}
+	for _, test := range tests {
+		if s := StructuresWithin(test.x, test.y, test.radius); !sameStructures(s, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, structureRects(test.expected...), structureRects(s...))
+		}
+	}
+}
+
+func TestNeighborsOf(t *testing.T) {
+	zone, plant, corner, station := newStructureTestCity(t)
+
+	tests := []struct {
+		name     string
+		s        *Structure
+		expected []*Structure
+	}{
+		{"zone", zone, []*Structure{station}},
+		{"police station", station, []*Structure{zone}},
+		{"power plant", plant, nil},
+		{"map edge", corner, nil},
+	}
+	for _, test := range tests {
+		if s := NeighborsOf(test.s); !sameStructures(s, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, structureRects(test.expected...), structureRects(s...))
+		}
+	}
+}
+
+func TestAdjacentTiles(t *testing.T) {
+	zone, plant, corner, _ := newStructureTestCity(t)
+
+	tests := []struct {
+		name  string
+		s     *Structure
+		tiles int
+	}{
+		{"zone", zone, 8},
+		{"power plant", plant, 20},
+		{"map edge", corner, 4},
+	}
+	for _, test := range tests {
+		tiles := test.s.AdjacentTiles()
+		if len(tiles) != test.tiles {
+			t.Errorf("%s: expected %d tiles, got %d", test.name, test.tiles, len(tiles))
+		}
+
+		// Each tile borders one side of the structure. Corners are not
+		// adjacent.
+		r := test.s.Rect()
+		found := make(map[image.Point]bool)
+		for _, p := range tiles {
+			if !ValidXY(p.X, p.Y) || p.In(r) || found[p] {
+				t.Fatalf("%s: unexpected tile %s", test.name, p)
+			}
+			found[p] = true
+			if test.s.Distance(p.X, p.Y) != 1 {
+				t.Errorf("%s: tile %s does not border the structure", test.name, p)
+			}
+		}
+	}
+}
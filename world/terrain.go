@@ -0,0 +1,343 @@
+package world
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// DefaultTerrainPreset is the name of the terrain preset used when no preset
+// is specified.
+const DefaultTerrainPreset = "default"
+
+// TerrainPreset controls the terrain generated for new maps. Fractions are of
+// the total area of the map.
+type TerrainPreset struct {
+	Scale float64 // Approximate size of terrain features in tiles
+
+	Water  float64 // Fraction of the map covered by water
+	Rock   float64 // Fraction of the map covered by rocky areas
+	Forest float64 // Fraction of the map covered by forests
+	Meadow float64 // Fraction of the map covered by meadows
+
+	ForestTrees float64 // Chance of a tree on each forest tile
+	MeadowTrees float64 // Chance of a tree on each meadow tile
+
+	Rivers int     // Number of rivers flowing across the map
+	Coast  float64 // Fraction of the map along one edge which slopes down to the sea
+	Relief float64 // Elevation of the highest terrain as a fraction of MaxElevation
+}
+
+// TerrainPresets are the available terrain presets, by name.
+var TerrainPresets = map[string]*TerrainPreset{
+	DefaultTerrainPreset: {
+		Scale:       24,
+		Water:       0.03,
+		Forest:      0.15,
+		Meadow:      0.15,
+		ForestTrees: 0.3,
+		MeadowTrees: 0.01,
+		Rivers:      1,
+		Relief:      0.5,
+	},
+	"coast": {
+		Scale:       32,
+		Water:       0.2,
+		Forest:      0.15,
+		Meadow:      0.2,
+		ForestTrees: 0.3,
+		MeadowTrees: 0.01,
+		Rivers:      1,
+		Coast:       0.3,
+		Relief:      0.5,
+	},
+	"forest": {
+		Scale:       32,
+		Water:       0.03,
+		Forest:      0.5,
+		Meadow:      0.2,
+		ForestTrees: 0.6,
+		MeadowTrees: 0.05,
+		Relief:      0.5,
+	},
+	"meadows": {
+		Scale:       48,
+		Water:       0.02,
+		Forest:      0.05,
+		Meadow:      0.6,
+		ForestTrees: 0.3,
+		MeadowTrees: 0.01,
+		Relief:      0.25,
+	},
+	"lakes": {
+		Scale:       40,
+		Water:       0.25,
+		Forest:      0.2,
+		Meadow:      0.25,
+		ForestTrees: 0.4,
+		MeadowTrees: 0.02,
+		Rivers:      2,
+		Relief:      0.5,
+	},
+	"rocky": {
+		Scale:       20,
+		Rock:        0.3,
+		Forest:      0.1,
+		Meadow:      0.1,
+		ForestTrees: 0.2,
+		Relief:      1,
+	},
+}
+
+// TerrainPresetNames returns the names of all terrain presets in alphabetical order.
+func TerrainPresetNames() []string {
+	var names []string
+	for name := range TerrainPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateTerrainPreset returns an error when no terrain preset exists with
+// the specified name.
+func ValidateTerrainPreset(name string) error {
+	if TerrainPresets[name] == nil {
+		return fmt.Errorf("unknown terrain preset %q (available presets: %s)", name, strings.Join(TerrainPresetNames(), ", "))
+	}
+	return nil
+}
+
+// noise generates deterministic fractal value noise.
+type noise struct {
+	seed uint64
+}
+
+// lattice returns a pseudo-random value between 0 and 1 for a lattice point.
+func (n noise) lattice(x int, y int) float64 {
+	h := n.seed ^ uint64(int64(x))*0x9E3779B97F4A7C15 ^ uint64(int64(y))*0xC2B2AE3D27D4EB4F
+	h ^= h >> 30
+	h *= 0xBF58476D1CE4E5B9
+	h ^= h >> 27
+	h *= 0x94D049BB133111EB
+	h ^= h >> 31
+	return float64(h>>11) / (1 << 53)
+}
+
+// at returns the smoothly interpolated noise value at the specified point.
+func (n noise) at(x float64, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	ix, iy := int(x0), int(y0)
+	fx, fy := x-x0, y-y0
+	fx, fy = fx*fx*(3-2*fx), fy*fy*(3-2*fy)
+
+	top := n.lattice(ix, iy) + (n.lattice(ix+1, iy)-n.lattice(ix, iy))*fx
+	bottom := n.lattice(ix, iy+1) + (n.lattice(ix+1, iy+1)-n.lattice(ix, iy+1))*fx
+	return top + (bottom-top)*fy
+}
+
+// fractal returns the sum of several octaves of noise, between 0 and 1.
+func (n noise) fractal(x float64, y float64, octaves int) float64 {
+	var v, total float64
+	amplitude, frequency := 1.0, 1.0
+	for i := 0; i < octaves; i++ {
+		v += n.at(x*frequency+float64(i)*17.3, y*frequency+float64(i)*31.7) * amplitude
+		total += amplitude
+		amplitude /= 2
+		frequency *= 2
+	}
+	return v / total
+}
+
+// quantile returns the value below which the specified fraction of values fall.
+func quantile(sorted []float64, fraction float64) float64 {
+	if fraction <= 0 {
+		return math.Inf(-1)
+	} else if fraction >= 1 {
+		return math.Inf(1)
+	}
+	return sorted[int(fraction*float64(len(sorted)-1))]
+}
+
+// riverWidth is the width of rivers in tiles. Rivers are as wide as a road so
+// that they may be bridged.
+const riverWidth = 2
+
+// carveRiver marks the tiles along a river which meanders from one edge of the
+// map to the opposite edge.
+func carveRiver(water []bool, width int, height int, r *rand.Rand) {
+	// Start at a random point along a random edge and flow towards the opposite edge.
+	var x, y, angle float64
+	switch r.Intn(4) {
+	case 0:
+		x, y, angle = 0, r.Float64()*float64(height), 0
+	case 1:
+		x, y, angle = float64(width-1), r.Float64()*float64(height), math.Pi
+	case 2:
+		x, y, angle = r.Float64()*float64(width), 0, math.Pi/2
+	default:
+		x, y, angle = r.Float64()*float64(width), float64(height-1), -math.Pi/2
+	}
+	heading := angle
+	meander := noise{seed: uint64(r.Int63())}
+
+	for step := 0; x >= 0 && y >= 0 && x < float64(width) && y < float64(height) && step < (width+height)*2; step++ {
+		for dx := 0; dx < riverWidth; dx++ {
+			for dy := 0; dy < riverWidth; dy++ {
+				tx, ty := int(x)+dx, int(y)+dy
+				if tx < width && ty < height {
+					water[tx*height+ty] = true
+				}
+			}
+		}
+
+		heading = angle + (meander.fractal(float64(step)/16, 0, 2)-0.5)*math.Pi*1.5
+		x += math.Cos(heading)
+		y += math.Sin(heading)
+	}
+}
+
+// coastDistance returns the distance of a point from the specified edge of
+// the map, between 0 and 1.
+func coastDistance(edge int, x int, y int, width int, height int) float64 {
+	switch edge {
+	case 0:
+		return float64(x) / float64(width)
+	case 1:
+		return float64(width-1-x) / float64(width)
+	case 2:
+		return float64(y) / float64(height)
+	default:
+		return float64(height-1-y) / float64(height)
+	}
+}
+
+// GenerateLevel generates a level with terrain. The same seed and preset
+// always generate the same terrain.
+func GenerateLevel(width int, height int, seed int64, preset *TerrainPreset) *GameLevel {
+	const octaves = 4
+
+	l := NewLevel(width, height)
+	r := rand.New(rand.NewSource(seed))
+	elevationNoise := noise{seed: uint64(r.Int63())}
+	moistureNoise := noise{seed: uint64(r.Int63())}
+
+	scale := preset.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	coastEdge := r.Intn(4)
+	elevation := make([]float64, width*height)
+	moisture := make([]float64, width*height)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			elevation[x*height+y] = elevationNoise.fractal(float64(x)/scale, float64(y)/scale, octaves)
+			moisture[x*height+y] = moistureNoise.fractal(float64(x)/scale, float64(y)/scale, octaves)
+
+			if preset.Coast > 0 {
+				d := coastDistance(coastEdge, x, y, width, height) / preset.Coast
+				if d < 1 {
+					elevation[x*height+y] -= 1 - d
+				}
+			}
+		}
+	}
+
+	// Convert the fractions of the map covered by each type of terrain into
+	// noise thresholds.
+	sortedElevation := append([]float64(nil), elevation...)
+	sort.Float64s(sortedElevation)
+	sortedMoisture := append([]float64(nil), moisture...)
+	sort.Float64s(sortedMoisture)
+	waterLevel := quantile(sortedElevation, preset.Water)
+	rockLevel := quantile(sortedElevation, 1-preset.Rock)
+	landLevel, highestLevel := waterLevel, sortedElevation[len(sortedElevation)-1]
+	if math.IsInf(landLevel, -1) {
+		landLevel = sortedElevation[0]
+	}
+	forestLevel := quantile(sortedMoisture, 1-preset.Forest)
+	meadowLevel := quantile(sortedMoisture, 1-preset.Forest-preset.Meadow)
+
+	water := make([]bool, width*height)
+	for i, e := range elevation {
+		water[i] = e < waterLevel
+	}
+	for i := 0; i < preset.Rivers; i++ {
+		carveRiver(water, width, height, r)
+	}
+	shore := func(x int, y int) bool {
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				tx, ty := x+dx, y+dy
+				if tx >= 0 && ty >= 0 && tx < width && ty < height && water[tx*height+ty] {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			e, m := elevation[x*height+y], moisture[x*height+y]
+
+			ground, treeChance := DirtTile, 0.0
+			switch {
+			case water[x*height+y]:
+				ground = WaterTile
+			case shore(x, y):
+				ground = SandTile
+			case e > rockLevel:
+				ground = RockTile
+			case m > forestLevel:
+				ground, treeChance = GrassTile, preset.ForestTrees
+			case m > meadowLevel:
+				ground, treeChance = GrassTile, preset.MeadowTrees
+			}
+			l.Tiles[0][x][y].EnvironmentSprite = TileGID(ground)
+
+			// Land rises in steps from the water level to the highest terrain.
+			// Water, including rivers carved through higher terrain, remains
+			// at the water level.
+			if !water[x*height+y] && e > landLevel && highestLevel > landLevel {
+				steps := int(preset.Relief * float64(MaxElevation+1) * (e - landLevel) / (highestLevel - landLevel))
+				if steps > MaxElevation {
+					steps = MaxElevation
+				}
+				l.Tiles[0][x][y].Height = steps
+			}
+
+			if treeChance > 0 && r.Float64() < treeChance {
+				tree := TreeTileB
+				if r.Intn(3) == 0 {
+					tree = TreeTileA
+				}
+				l.Tiles[1][x][y].EnvironmentSprite = TileGID(tree)
+			}
+		}
+	}
+	return l
+}
+
+// IsWater returns whether the ground at the specified location is water.
+// Roads built over water remain water, as bridges.
+func (l *GameLevel) IsWater(x int, y int) bool {
+	return x >= 0 && y >= 0 && x < l.width && y < l.height && l.Tiles[0][x][y].EnvironmentSprite == TileGID(WaterTile)
+}
+
+// IsBridge returns whether a road has been built over water at the specified location.
+func (l *GameLevel) IsBridge(x int, y int) bool {
+	return l.IsWater(x, y) && l.Tiles[0][x][y].Structure == StructureRoad
+}
+
+// GenerateTerrain replaces the current level with a level of the same size
+// generated using the world seed and terrain preset.
+func GenerateTerrain() {
+	preset := TerrainPresets[World.TerrainPreset]
+	if preset == nil {
+		preset = TerrainPresets[DefaultTerrainPreset]
+	}
+	World.Level = GenerateLevel(World.Level.width, World.Level.height, World.Seed, preset)
+}
@@ -0,0 +1,150 @@
+package world
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGenerateLevelDeterministic(t *testing.T) {
+	for _, name := range TerrainPresetNames() {
+		t.Run(name, func(t *testing.T) {
+			a := GenerateLevel(96, 64, 42, TerrainPresets[name])
+			b := GenerateLevel(96, 64, 42, TerrainPresets[name])
+			for i := range a.Tiles {
+				for x := range a.Tiles[i] {
+					for y := range a.Tiles[i][x] {
+						if *a.Tiles[i][x][y] != *b.Tiles[i][x][y] {
+							t.Fatalf("layer %d tile %d,%d differs: %+v != %+v", i, x, y, *a.Tiles[i][x][y], *b.Tiles[i][x][y])
+						}
+					}
+				}
+			}
+
+			c := GenerateLevel(96, 64, 43, TerrainPresets[name])
+			same := true
+			for x := range a.Tiles[0] {
+				for y := range a.Tiles[0][x] {
+					if *a.Tiles[0][x][y] != *c.Tiles[0][x][y] {
+						same = false
+					}
+				}
+			}
+			if same {
+				t.Errorf("seeds 42 and 43 generated the same terrain")
+			}
+		})
+	}
+}
+
+func TestResetSeed(t *testing.T) {
+	defer func() {
+		World.MapSeed = 0
+	}()
+
+	World.MapWidth, World.MapHeight = 32, 32
+	World.MapSeed = 42
+	Reset()
+	World.Seed = 7 // Loading a city replaces the seed.
+	Reset()
+	if World.Seed != 42 {
+		t.Fatalf("expected seed 42, got %d", World.Seed)
+	}
+
+	// A new seed is chosen for each new map when no seed is specified.
+	World.MapSeed = 0
+	Reset()
+	seed := World.Seed
+	Reset()
+	if World.Seed == 0 || World.Seed == seed {
+		t.Errorf("expected new seed, got %d and %d", seed, World.Seed)
+	}
+}
+
+func TestGenerateLevelFractions(t *testing.T) {
+	tests := []struct {
+		preset             string
+		minWater, maxWater float64 // Rivers add water to lakes and the sea
+		minTrees, maxTrees float64
+	}{
+		{"coast", 0.19, 0.26, 0.02, 0.06},
+		{"default", 0.025, 0.09, 0.03, 0.06},
+		{"forest", 0.025, 0.04, 0.25, 0.35},
+		{"lakes", 0.24, 0.34, 0.03, 0.1},
+		{"meadows", 0.015, 0.03, 0.01, 0.03},
+		{"rocky", 0, 0, 0.005, 0.025},
+	}
+	if len(tests) != len(TerrainPresets) {
+		t.Fatalf("expected %d presets to be tested, got %d", len(TerrainPresets), len(tests))
+	}
+	for _, test := range tests {
+		for seed := int64(1); seed <= 3; seed++ {
+			t.Run(fmt.Sprintf("%s/%d", test.preset, seed), func(t *testing.T) {
+				const width, height = 128, 96
+				l := GenerateLevel(width, height, seed, TerrainPresets[test.preset])
+
+				var water, trees int
+				for x := 0; x < width; x++ {
+					for y := 0; y < height; y++ {
+						if l.IsWater(x, y) {
+							water++
+						}
+						if l.Tiles[1][x][y].EnvironmentSprite != 0 {
+							trees++
+						}
+					}
+				}
+				waterFraction, treeFraction := float64(water)/(width*height), float64(trees)/(width*height)
+				if waterFraction < test.minWater || waterFraction > test.maxWater {
+					t.Errorf("expected water fraction between %.2f and %.2f, got %.3f", test.minWater, test.maxWater, waterFraction)
+				}
+				if treeFraction < test.minTrees || treeFraction > test.maxTrees {
+					t.Errorf("expected tree fraction between %.3f and %.3f, got %.3f", test.minTrees, test.maxTrees, treeFraction)
+				}
+			})
+		}
+	}
+}
+
+func TestGenerateLevelBounds(t *testing.T) {
+	tests := []struct {
+		width, height int
+	}{
+		{MinMapSize, MinMapSize},
+		{128, 64},
+		{64, 200},
+	}
+	for _, name := range TerrainPresetNames() {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("%s/%dx%d", name, test.width, test.height), func(t *testing.T) {
+				l := GenerateLevel(test.width, test.height, 1, TerrainPresets[name])
+				if l.width != test.width || l.height != test.height {
+					t.Fatalf("expected size %dx%d, got %dx%d", test.width, test.height, l.width, l.height)
+				}
+				for i := range l.Tiles {
+					if len(l.Tiles[i]) != test.width {
+						t.Fatalf("layer %d: expected width %d, got %d", i, test.width, len(l.Tiles[i]))
+					}
+					for x := range l.Tiles[i] {
+						if len(l.Tiles[i][x]) != test.height {
+							t.Fatalf("layer %d: expected height %d, got %d", i, test.height, len(l.Tiles[i][x]))
+						}
+					}
+				}
+				for x := 0; x < test.width; x++ {
+					for y := 0; y < test.height; y++ {
+						tile := l.Tiles[0][x][y]
+						if tile.EnvironmentSprite == 0 {
+							t.Fatalf("tile %d,%d has no ground", x, y)
+						}
+						if tile.Height < 0 || tile.Height > MaxElevation {
+							t.Fatalf("tile %d,%d has invalid height %d", x, y, tile.Height)
+						}
+						if l.IsWater(x, y) && tile.Height != 0 {
+							t.Fatalf("water at %d,%d is above the water level at height %d", x, y, tile.Height)
+						}
+					}
+				}
+			})
+		}
+	}
+}
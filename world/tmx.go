@@ -0,0 +1,528 @@
+package world
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"code.rocketnine.space/tslocum/citylimits/asset"
+	"github.com/lafriks/go-tiled"
+)
+
+// tilesetName is the name of the tileset shared by all maps.
+const tilesetName = "MRMO_BRIK"
+
+// structuresObjectGroup is the name of the object layer which holds zones and
+// power plants in exported maps.
+const structuresObjectGroup = "structures"
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:"value,attr"`
+}
+
+type tmxTileset struct {
+	FirstGID uint32 `xml:"firstgid,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+type tmxData struct {
+	Encoding string `xml:"encoding,attr"`
+	Data     string `xml:",innerxml"` // CSV data never requires escaping
+}
+
+type tmxLayer struct {
+	ID         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Width      int           `xml:"width,attr"`
+	Height     int           `xml:"height,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+	Data       tmxData       `xml:"data"`
+}
+
+type tmxObject struct {
+	ID         int           `xml:"id,attr"`
+	Name       string        `xml:"name,attr"`
+	Type       string        `xml:"type,attr"`
+	X          int           `xml:"x,attr"`
+	Y          int           `xml:"y,attr"`
+	Width      int           `xml:"width,attr"`
+	Height     int           `xml:"height,attr"`
+	Properties []tmxProperty `xml:"properties>property"`
+}
+
+type tmxObjectGroup struct {
+	ID      int         `xml:"id,attr"`
+	Name    string      `xml:"name,attr"`
+	Objects []tmxObject `xml:"object"`
+}
+
+type tmxMap struct {
+	XMLName      xml.Name       `xml:"map"`
+	Version      string         `xml:"version,attr"`
+	TiledVersion string         `xml:"tiledversion,attr"`
+	Orientation  string         `xml:"orientation,attr"`
+	RenderOrder  string         `xml:"renderorder,attr"`
+	Width        int            `xml:"width,attr"`
+	Height       int            `xml:"height,attr"`
+	TileWidth    int            `xml:"tilewidth,attr"`
+	TileHeight   int            `xml:"tileheight,attr"`
+	Infinite     int            `xml:"infinite,attr"`
+	NextLayerID  int            `xml:"nextlayerid,attr"`
+	NextObjectID int            `xml:"nextobjectid,attr"`
+	Properties   []tmxProperty  `xml:"properties>property"`
+	Tileset      tmxTileset     `xml:"tileset"`
+	Layers       []*tmxLayer    `xml:"layer"`
+	ObjectGroup  tmxObjectGroup `xml:"objectgroup"`
+}
+
+// StructureName returns the name of a structure type, which matches the name
+// of its map file.
+func StructureName(structureType int) string {
+	return strings.TrimSuffix(path.Base(StructureFilePaths[structureType]), ".tmx")
+}
+
+// structureTypeByName returns the type of a structure which is exported as an
+// object, such as a zone, power plant or police station, by its name.
+func structureTypeByName(name string) int {
+	for structureType := range StructureFilePaths {
+		if isRegisteredStructure(structureType) && StructureName(structureType) == name {
+			return structureType
+		}
+	}
+	return 0
+}
+
+// powerPriorityName returns the name of the zone type supplied with power
+// first, or fair when power is shared fairly.
+func powerPriorityName(zoneType int) string {
+	if zoneType == 0 {
+		return "fair"
+	}
+	return StructureName(zoneType)
+}
+
+// structureSize returns the width and height of a structure in tiles.
+func structureSize(structureType int) (int, int, error) {
+	m, err := LoadMap(structureType)
+	if err != nil {
+		return 0, 0, err
+	}
+	return m.Width, m.Height, nil
+}
+
+func tmxLayerData(width int, height int, sprite func(x, y int) uint32) tmxData {
+	var b strings.Builder
+	b.WriteByte('\n')
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			b.WriteString(strconv.FormatUint(uint64(sprite(x, y)), 10))
+			if x < width-1 || y < height-1 {
+				b.WriteByte(',')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return tmxData{Encoding: "csv", Data: b.String()}
+}
+
+// tmxHeights returns the elevation of each ground tile as comma-separated
+// values, in the same order as layer data.
+func tmxHeights(level *GameLevel) string {
+	var b strings.Builder
+	for y := 0; y < level.height; y++ {
+		for x := 0; x < level.width; x++ {
+			if x > 0 || y > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strconv.Itoa(level.Tiles[0][x][y].Height))
+		}
+	}
+	return b.String()
+}
+
+// parseTMXHeights sets the elevation of each ground tile from comma-separated values.
+func parseTMXHeights(level *GameLevel, heights string) error {
+	values := strings.Split(heights, ",")
+	if len(values) != level.width*level.height {
+		return errors.New("invalid heights")
+	}
+	for i, v := range values {
+		h, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || h < 0 || h > MaxElevation {
+			return errors.New("invalid heights")
+		}
+		level.Tiles[0][i%level.width][i/level.width].Height = h
+	}
+	return nil
+}
+
+// ExportTMX writes the current city to w as a Tiled map. Each layer of the
+// level is exported as a sprite layer and an environment layer. Zones and
+// power plants are exported as objects.
+func ExportTMX(w io.Writer) error {
+	width, height := World.Level.width, World.Level.height
+	m := &tmxMap{
+		Version:      "1.5",
+		TiledVersion: "1.7.2",
+		Orientation:  "isometric",
+		RenderOrder:  "right-down",
+		Width:        width,
+		Height:       height,
+		TileWidth:    TileSize,
+		TileHeight:   TileSize / 2,
+		Properties: []tmxProperty{
+			{Name: "seed", Value: strconv.FormatInt(World.Seed, 10)},
+			{Name: "ticks", Type: "int", Value: strconv.Itoa(World.Ticks)},
+			{Name: "funds", Type: "int", Value: strconv.Itoa(World.Funds)},
+			{Name: "tax_residential", Type: "float", Value: strconv.FormatFloat(World.TaxR, 'f', -1, 64)},
+			{Name: "tax_commercial", Type: "float", Value: strconv.FormatFloat(World.TaxC, 'f', -1, 64)},
+			{Name: "tax_industrial", Type: "float", Value: strconv.FormatFloat(World.TaxI, 'f', -1, 64)},
+			{Name: "police_funding", Type: "float", Value: strconv.FormatFloat(World.PoliceFunding, 'f', -1, 64)},
+			{Name: "fire_funding", Type: "float", Value: strconv.FormatFloat(World.FireFunding, 'f', -1, 64)},
+			{Name: "power_priority", Value: powerPriorityName(World.PowerPriority)},
+			{Name: "heights", Value: tmxHeights(World.Level)},
+		},
+		Tileset: tmxTileset{
+			FirstGID: tilesetFirstGID,
+			Source:   tilesetName + ".tsx",
+		},
+	}
+
+	var layerID int
+	for i := range World.Level.Tiles {
+		tiles := World.Level.Tiles[i]
+		for _, environment := range []bool{true, false} {
+			layerID++
+			layer := &tmxLayer{
+				ID:     layerID,
+				Name:   strconv.Itoa(i),
+				Width:  width,
+				Height: height,
+				Properties: []tmxProperty{
+					{Name: "level", Type: "int", Value: strconv.Itoa(i)},
+					{Name: "environment", Type: "bool", Value: strconv.FormatBool(environment)},
+				},
+			}
+			if environment {
+				layer.Name += " environment"
+				layer.Data = tmxLayerData(width, height, func(x, y int) uint32 {
+					return tiles[x][y].EnvironmentSprite
+				})
+			} else {
+				layer.Data = tmxLayerData(width, height, func(x, y int) uint32 {
+					return tiles[x][y].Sprite
+				})
+			}
+			m.Layers = append(m.Layers, layer)
+		}
+	}
+
+	layerID++
+	m.ObjectGroup = tmxObjectGroup{
+		ID:   layerID,
+		Name: structuresObjectGroup,
+	}
+	addObject := func(structureType int, x int, y int, properties []tmxProperty) error {
+		w, h, err := structureSize(structureType)
+		if err != nil {
+			return err
+		}
+		m.ObjectGroup.Objects = append(m.ObjectGroup.Objects, tmxObject{
+			ID:         len(m.ObjectGroup.Objects) + 1,
+			Name:       StructureTooltips[structureType],
+			Type:       StructureName(structureType),
+			X:          (x - (w - 1)) * m.TileHeight,
+			Y:          (y - (h - 1)) * m.TileHeight,
+			Width:      w * m.TileHeight,
+			Height:     h * m.TileHeight,
+			Properties: properties,
+		})
+		return nil
+	}
+	for _, zone := range World.Zones {
+		err := addObject(zone.Type, zone.X, zone.Y, []tmxProperty{
+			{Name: "population", Type: "int", Value: strconv.Itoa(zone.Population)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, plant := range World.PowerPlants {
+		err := addObject(plant.Type, plant.X, plant.Y, []tmxProperty{
+			{Name: "age", Type: "int", Value: strconv.Itoa(plant.Age)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, s := range World.Structures {
+		if s.Zone != nil || s.PowerPlant != nil {
+			continue
+		}
+		err := addObject(s.Type, s.X, s.Y, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.NextLayerID = layerID + 1
+	m.NextObjectID = len(m.ObjectGroup.Objects) + 1
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	err = enc.Encode(m)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// ExportTMXFile exports the current city to the specified Tiled map file. The
+// tileset is copied to the same directory when it is not already present.
+func ExportTMXFile(filePath string) error {
+	dir := filepath.Dir(filePath)
+	for _, name := range []string{tilesetName + ".tsx", tilesetName + ".png"} {
+		err := copyAsset(path.Join("image/tileset", name), filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	err = ExportTMX(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// copyAsset copies an embedded asset to the specified file unless the file
+// already exists.
+func copyAsset(assetPath string, filePath string) error {
+	_, err := os.Stat(filePath)
+	if err == nil {
+		return nil
+	}
+
+	buf, err := asset.FS.ReadFile(assetPath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, buf, 0644)
+}
+
+// ImportTMXFile replaces the current city with the city in the specified Tiled
+// map file. Layers are assigned to level layers via their "level" and
+// "environment" properties. Layers without these properties are assigned to
+// the sprites of each level layer in order. Zones and power plants are
+// imported from objects whose type is the name of the structure.
+func ImportTMXFile(filePath string) error {
+	m, err := tiled.LoadFile(filePath)
+	if err != nil {
+		return err
+	}
+
+	if m.Orientation != "isometric" {
+		return fmt.Errorf("unsupported map orientation: %s", m.Orientation)
+	} else if m.Width < MinMapSize || m.Height < MinMapSize || m.Width > MaxMapSize || m.Height > MaxMapSize {
+		return fmt.Errorf("unsupported map size: %dx%d", m.Width, m.Height)
+	}
+
+	level := NewLevel(m.Width, m.Height)
+	var nextLevel int
+	for _, layer := range m.Layers {
+		levelNum := nextLevel
+		if len(layer.Properties.Get("level")) > 0 {
+			levelNum = layer.Properties.GetInt("level")
+		}
+		environment := layer.Properties.GetBool("environment")
+		if !environment {
+			nextLevel = levelNum + 1
+		}
+		if levelNum < 0 {
+			return fmt.Errorf("invalid level of layer %s: %d", layer.Name, levelNum)
+		}
+
+		for levelNum > len(level.Tiles)-1 {
+			level.AddLayer()
+		}
+		for y := 0; y < m.Height; y++ {
+			for x := 0; x < m.Width; x++ {
+				t := layer.Tiles[y*m.Width+x]
+				if t == nil || t.Nil {
+					continue
+				}
+				if t.Tileset == nil || t.Tileset.Name != tilesetName {
+					return fmt.Errorf("unsupported tileset in layer %s", layer.Name)
+				}
+
+				if environment {
+					level.Tiles[levelNum][x][y].EnvironmentSprite = TileGID(t.ID)
+				} else {
+					level.Tiles[levelNum][x][y].Sprite = TileGID(t.ID)
+				}
+			}
+		}
+	}
+
+	var (
+		zones       []*Zone
+		powerPlants []*PowerPlant
+	)
+	for _, group := range m.ObjectGroups {
+		for _, o := range group.Objects {
+			structureType := structureTypeByName(o.Type)
+			if structureType == 0 {
+				continue // Not a structure.
+			}
+
+			w, h, err := structureSize(structureType)
+			if err != nil {
+				return err
+			}
+			x := int(o.X)/m.TileHeight + w - 1
+			y := int(o.Y)/m.TileHeight + h - 1
+			if x-(w-1) < 0 || y-(h-1) < 0 || x >= m.Width || y >= m.Height {
+				return fmt.Errorf("invalid location of %s at %d,%d", o.Type, x, y)
+			}
+
+			for tx := x - (w - 1); tx <= x; tx++ {
+				for ty := y - (h - 1); ty <= y; ty++ {
+					if level.Tiles[0][tx][ty].Structure != 0 {
+						return fmt.Errorf("invalid location of %s at %d,%d: space already occupied", o.Type, x, y)
+					}
+					level.Tiles[0][tx][ty].Structure = structureType
+				}
+			}
+
+			if IsZone(structureType) {
+				population := o.Properties.GetInt("population")
+				if population < 0 || population > MaxZonePopulation {
+					return fmt.Errorf("invalid population of %s at %d,%d", o.Type, x, y)
+				}
+				zones = append(zones, &Zone{
+					Type:       structureType,
+					X:          x,
+					Y:          y,
+					Population: population,
+				})
+			} else if IsPowerPlant(structureType) {
+				age := o.Properties.GetInt("age")
+				if age < 0 {
+					return fmt.Errorf("invalid age of %s at %d,%d", o.Type, x, y)
+				}
+				powerPlants = append(powerPlants, &PowerPlant{
+					Type: structureType,
+					X:    x,
+					Y:    y,
+					Age:  age,
+				})
+			}
+		}
+	}
+
+	// Identify roads and power lines by their sprites.
+	power := newPowerMap(m.Width, m.Height)
+	for x := range level.Tiles[0] {
+		for y, tile := range level.Tiles[0][x] {
+			if tile.Structure != 0 {
+				continue
+			}
+			if (tile.Sprite == TileGID(RoadTile) || tile.Sprite == TileGID(BridgeTile)) && level.Tiles[1][x][y].Sprite == 0 {
+				tile.Structure = StructureRoad
+				power[x][y].CarriesPower = true
+			} else if tile.Sprite == 0 && level.Tiles[1][x][y].Sprite == TileGID(PowerLineTile) {
+				tile.Structure = StructurePowerLine
+				power[x][y].CarriesPower = true
+			} else if tile.Sprite == 0 && level.Tiles[1][x][y].Sprite == TileGID(HighVoltageLineTile) {
+				tile.Structure = StructureHighVoltageLine
+				power[x][y].CarriesPower = true
+			}
+		}
+	}
+
+	seed, ticks, funds := World.Seed, 0, startingFunds
+	taxR, taxC, taxI := World.TaxR, World.TaxC, World.TaxI
+	policeFunding, fireFunding := World.PoliceFunding, World.FireFunding
+	powerPriority := World.PowerPriority
+	if m.Properties != nil {
+		p := *m.Properties
+		if v, err := strconv.ParseInt(p.GetString("seed"), 10, 64); err == nil && v != 0 {
+			seed = v
+		}
+		if len(p.Get("ticks")) > 0 {
+			ticks = p.GetInt("ticks")
+		}
+		if len(p.Get("funds")) > 0 {
+			funds = p.GetInt("funds")
+		}
+		if len(p.Get("tax_residential")) > 0 {
+			taxR = p.GetFloat("tax_residential")
+		}
+		if len(p.Get("tax_commercial")) > 0 {
+			taxC = p.GetFloat("tax_commercial")
+		}
+		if len(p.Get("tax_industrial")) > 0 {
+			taxI = p.GetFloat("tax_industrial")
+		}
+		if len(p.Get("police_funding")) > 0 {
+			policeFunding = p.GetFloat("police_funding")
+		}
+		if len(p.Get("fire_funding")) > 0 {
+			fireFunding = p.GetFloat("fire_funding")
+		}
+		if name := p.GetString("power_priority"); name == "fair" {
+			powerPriority = 0
+		} else if name != "" {
+			powerPriority = structureTypeByName(name)
+			if !IsZone(powerPriority) {
+				return fmt.Errorf("invalid power priority %s", name)
+			}
+		}
+		if heights := p.GetString("heights"); heights != "" {
+			err = parseTMXHeights(level, heights)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if ticks < 0 {
+		return errors.New("invalid ticks")
+	}
+	for _, tax := range []float64{taxR, taxC, taxI} {
+		if tax < 0 || tax > 1 {
+			return errors.New("invalid tax rate")
+		}
+	}
+	if policeFunding < 0 || policeFunding > 1 {
+		return errors.New("invalid police funding")
+	}
+	if fireFunding < 0 || fireFunding > 1 {
+		return errors.New("invalid fire funding")
+	}
+
+	World.Seed, World.Ticks, World.Funds = seed, ticks, funds
+	World.TaxR, World.TaxC, World.TaxI = taxR, taxC, taxI
+	World.PoliceFunding, World.FireFunding = policeFunding, fireFunding
+	World.PowerPriority = powerPriority
+	setCity(level, power, newPollutionMap(level.width, level.height), zones, powerPlants, nil)
+	return nil
+}
@@ -0,0 +1,50 @@
+package world
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportTMXInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+	}{
+		{"tax rate", `name="tax_commercial" type="float" value="`, `name="tax_commercial" type="float" value="-`},
+		{"police funding", `name="police_funding" type="float" value="`, `name="police_funding" type="float" value="2`},
+		{"fire funding", `name="fire_funding" type="float" value="`, `name="fire_funding" type="float" value="-`},
+		{"zone population", `name="population" type="int" value="3"`, `name="population" type="int" value="11"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestCity(t)
+			filePath := filepath.Join(t.TempDir(), "city.tmx")
+			err := ExportTMXFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ImportTMXFile(filePath)
+			if err != nil {
+				t.Fatalf("failed to import exported city: %s", err)
+			}
+
+			buf, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(buf), test.old) {
+				t.Fatalf("property %q not exported", test.old)
+			}
+			err = os.WriteFile(filePath, []byte(strings.Replace(string(buf), test.old, test.new, 1)), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ImportTMXFile(filePath)
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package world
+
+import (
+	"errors"
+	"image"
+)
+
+// undoLimit is the maximum number of operations which may be undone.
+const undoLimit = 32
+
+// undoMargin is the number of tiles surrounding a command's location which
+// are checked for changes. It must cover the largest structure, as
+// bulldozing a single tile removes the entire structure.
+const undoMargin = 5
+
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+type undoTile struct {
+	Layer, X, Y int
+	Tile        Tile
+}
+
+type undoPowerTile struct {
+	X, Y         int
+	CarriesPower bool
+}
+
+// undoRecord holds the state of the world before a command was applied.
+type undoRecord struct {
+	command *Command
+	month   int
+
+	tiles []undoTile
+	power []undoPowerTile
+	cost  int
+
+	// Zones and power plants added or removed by the command. Structures
+	// destroyed by the simulation afterward are not affected by undo.
+	addedZones, removedZones   []*Zone
+	addedPlants, removedPlants []*PowerPlant
+
+	rect         image.Rectangle
+	before       [][]Tile      // Tiles within rect by layer, used while recording
+	zonesBefore  []*Zone       // Used while recording
+	plantsBefore []*PowerPlant // Used while recording
+}
+
+// isUndoable returns whether a command may be undone.
+func isUndoable(c *Command) bool {
+	return c.Type == CommandBuildStructure || c.Type == CommandBulldoze || c.Type == CommandBuildRoad || c.Type == CommandBuildPowerLine
+}
+
+func currentMonth() int {
+	return World.Ticks / MonthTicks
+}
+
+// commandRect returns the area which may be modified by a command.
+func commandRect(c *Command) image.Rectangle {
+	r := image.Rect(c.X, c.Y, c.X+1, c.Y+1)
+	if c.Type == CommandBuildRoad || c.Type == CommandBuildPowerLine {
+		r = r.Union(image.Rect(c.ToX, c.ToY, c.ToX+1, c.ToY+1))
+	}
+	r = r.Inset(-undoMargin)
+	return r.Intersect(image.Rect(0, 0, World.Level.width, World.Level.height))
+}
+
+// beginUndoRecord captures the state of the area which may be modified by a command.
+func beginUndoRecord(c *Command) *undoRecord {
+	record := &undoRecord{
+		command:      c,
+		month:        currentMonth(),
+		cost:         World.Funds,
+		rect:         commandRect(c),
+		zonesBefore:  append([]*Zone(nil), World.Zones...),
+		plantsBefore: append([]*PowerPlant(nil), World.PowerPlants...),
+	}
+	for i := range World.Level.Tiles {
+		var tiles []Tile
+		for x := record.rect.Min.X; x < record.rect.Max.X; x++ {
+			for y := record.rect.Min.Y; y < record.rect.Max.Y; y++ {
+				tiles = append(tiles, *World.Level.Tiles[i][x][y])
+			}
+		}
+		record.before = append(record.before, tiles)
+	}
+	for x := record.rect.Min.X; x < record.rect.Max.X; x++ {
+		for y := record.rect.Min.Y; y < record.rect.Max.Y; y++ {
+			record.power = append(record.power, undoPowerTile{x, y, World.Power[x][y].CarriesPower})
+		}
+	}
+	return record
+}
+
+// finishUndoRecord retains only the tiles modified by a command and adds the
+// record to the undo history. It returns false when nothing was modified.
+func finishUndoRecord(record *undoRecord) bool {
+	record.cost -= World.Funds
+
+	for i := range World.Level.Tiles {
+		var j int
+		for x := record.rect.Min.X; x < record.rect.Max.X; x++ {
+			for y := record.rect.Min.Y; y < record.rect.Max.Y; y++ {
+				var before Tile
+				if i < len(record.before) {
+					before = record.before[i][j]
+				}
+				j++
+
+				if *World.Level.Tiles[i][x][y] != before {
+					record.tiles = append(record.tiles, undoTile{i, x, y, before})
+				}
+			}
+		}
+	}
+	record.before = nil
+
+	var power []undoPowerTile
+	for _, t := range record.power {
+		if World.Power[t.X][t.Y].CarriesPower != t.CarriesPower {
+			power = append(power, t)
+		}
+	}
+	record.power = power
+
+	record.addedZones, record.removedZones = diffZones(record.zonesBefore, World.Zones)
+	record.addedPlants, record.removedPlants = diffPowerPlants(record.plantsBefore, World.PowerPlants)
+	record.zonesBefore, record.plantsBefore = nil, nil
+
+	if len(record.tiles) == 0 && len(record.power) == 0 && len(record.addedZones) == 0 && len(record.removedZones) == 0 && len(record.addedPlants) == 0 && len(record.removedPlants) == 0 {
+		return false
+	}
+
+	World.undoHistory = append(World.undoHistory, record)
+	if len(World.undoHistory) > undoLimit {
+		World.undoHistory = World.undoHistory[len(World.undoHistory)-undoLimit:]
+	}
+	return true
+}
+
+// diffZones returns the zones in after which are not in before, and the zones
+// in before which are not in after.
+func diffZones(before []*Zone, after []*Zone) (added []*Zone, removed []*Zone) {
+	inBefore := make(map[*Zone]bool, len(before))
+	for _, zone := range before {
+		inBefore[zone] = true
+	}
+	for _, zone := range after {
+		if inBefore[zone] {
+			delete(inBefore, zone)
+		} else {
+			added = append(added, zone)
+		}
+	}
+	for _, zone := range before {
+		if inBefore[zone] {
+			removed = append(removed, zone)
+		}
+	}
+	return added, removed
+}
+
+// diffPowerPlants returns the power plants in after which are not in before,
+// and the power plants in before which are not in after.
+func diffPowerPlants(before []*PowerPlant, after []*PowerPlant) (added []*PowerPlant, removed []*PowerPlant) {
+	inBefore := make(map[*PowerPlant]bool, len(before))
+	for _, plant := range before {
+		inBefore[plant] = true
+	}
+	for _, plant := range after {
+		if inBefore[plant] {
+			delete(inBefore, plant)
+		} else {
+			added = append(added, plant)
+		}
+	}
+	for _, plant := range before {
+		if inBefore[plant] {
+			removed = append(removed, plant)
+		}
+	}
+	return added, removed
+}
+
+// expireUndoHistory discards operations made before the current month.
+func expireUndoHistory() {
+	month := currentMonth()
+	if len(World.undoHistory) > 0 && World.undoHistory[len(World.undoHistory)-1].month != month {
+		World.undoHistory = nil
+	}
+	if len(World.redoHistory) > 0 && World.redoHistory[len(World.redoHistory)-1].month != month {
+		World.redoHistory = nil
+	}
+}
+
+func clearUndoHistory() {
+	World.undoHistory = nil
+	World.redoHistory = nil
+}
+
+// undo reverts the last operation made during the current month.
+func undo() error {
+	expireUndoHistory()
+	if len(World.undoHistory) == 0 {
+		ShowMessage("Nothing to undo", 3)
+		return ErrNothingToUndo
+	}
+
+	record := World.undoHistory[len(World.undoHistory)-1]
+	World.undoHistory = World.undoHistory[:len(World.undoHistory)-1]
+
+	for _, t := range record.tiles {
+		*World.Level.Tiles[t.Layer][t.X][t.Y] = t.Tile
+	}
+	for _, t := range record.power {
+		World.Power.SetTile(t.X, t.Y, t.CarriesPower)
+	}
+	// Only the zones and power plants added or removed by the command are
+	// restored. The remaining zones and power plants are left as they are.
+	World.Zones, _ = diffZones(record.addedZones, World.Zones)
+	World.Zones = append(World.Zones, record.removedZones...)
+	World.PowerPlants, _ = diffPowerPlants(record.addedPlants, World.PowerPlants)
+	World.PowerPlants = append(World.PowerPlants, record.removedPlants...)
+	rebuildStructures()
+	World.Funds += record.cost
+
+	World.Level.ClearHoverSprites()
+	World.PowerUpdated = true
+	World.HUDUpdated = true
+
+	World.redoHistory = append(World.redoHistory, record)
+
+	if record.cost > 0 {
+		ShowMessage(World.Printer.Sprintf("Undid %s (+$%d)", record.command, record.cost), 3)
+	} else {
+		ShowMessage(World.Printer.Sprintf("Undid %s", record.command), 3)
+	}
+	return nil
+}
+
+// redo applies the last undone operation again.
+func redo() error {
+	expireUndoHistory()
+	if len(World.redoHistory) == 0 {
+		ShowMessage("Nothing to redo", 3)
+		return ErrNothingToRedo
+	}
+
+	record := World.redoHistory[len(World.redoHistory)-1]
+	World.redoHistory = World.redoHistory[:len(World.redoHistory)-1]
+
+	redoHistory := World.redoHistory
+	err := ApplyCommand(record.command)
+	World.redoHistory = redoHistory
+	return err
+}
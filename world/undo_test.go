@@ -0,0 +1,174 @@
+package world
+
+import (
+	"errors"
+	"testing"
+)
+
+// citySnapshot holds the state of a city which is restored by undo.
+type citySnapshot struct {
+	tiles  [][][]Tile
+	power  [][]bool
+	funds  int
+	zones  []Zone
+	plants []PowerPlant
+}
+
+func takeSnapshot() *citySnapshot {
+	s := &citySnapshot{
+		funds: World.Funds,
+	}
+	for i := range World.Level.Tiles {
+		layer := make([][]Tile, len(World.Level.Tiles[i]))
+		for x := range World.Level.Tiles[i] {
+			for _, tile := range World.Level.Tiles[i][x] {
+				t := *tile
+				t.HoverSprite = 0
+				layer[x] = append(layer[x], t)
+			}
+		}
+		s.tiles = append(s.tiles, layer)
+	}
+	for x := range World.Power {
+		column := make([]bool, len(World.Power[x]))
+		for y := range World.Power[x] {
+			column[y] = World.Power[x][y].CarriesPower
+		}
+		s.power = append(s.power, column)
+	}
+	for _, zone := range World.Zones {
+		s.zones = append(s.zones, *zone)
+	}
+	for _, plant := range World.PowerPlants {
+		s.plants = append(s.plants, *plant)
+	}
+	return s
+}
+
+// compareSnapshot reports the differences between a snapshot and the city.
+func compareSnapshot(t *testing.T, name string, expected *citySnapshot) {
+	t.Helper()
+
+	got := takeSnapshot()
+	for i := range expected.tiles {
+		for x := range expected.tiles[i] {
+			for y := range expected.tiles[i][x] {
+				if got.tiles[i][x][y] != expected.tiles[i][x][y] {
+					t.Fatalf("%s: layer %d tile %d,%d: expected %+v, got %+v", name, i, x, y, expected.tiles[i][x][y], got.tiles[i][x][y])
+				}
+			}
+		}
+	}
+	for x := range expected.power {
+		for y := range expected.power[x] {
+			if got.power[x][y] != expected.power[x][y] {
+				t.Fatalf("%s: power tile %d,%d: expected %v, got %v", name, x, y, expected.power[x][y], got.power[x][y])
+			}
+		}
+	}
+	if got.funds != expected.funds {
+		t.Errorf("%s: expected funds %d, got %d", name, expected.funds, got.funds)
+	}
+	if len(got.zones) != len(expected.zones) {
+		t.Fatalf("%s: expected %d zones, got %d", name, len(expected.zones), len(got.zones))
+	}
+	for i := range expected.zones {
+		if got.zones[i] != expected.zones[i] {
+			t.Errorf("%s: zone %d: expected %+v, got %+v", name, i, expected.zones[i], got.zones[i])
+		}
+	}
+	if len(got.plants) != len(expected.plants) {
+		t.Fatalf("%s: expected %d power plants, got %d", name, len(expected.plants), len(got.plants))
+	}
+	for i := range expected.plants {
+		if got.plants[i] != expected.plants[i] {
+			t.Errorf("%s: power plant %d: expected %+v, got %+v", name, i, expected.plants[i], got.plants[i])
+		}
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	tests := []struct {
+		name    string
+		command *Command
+	}{
+		{"build zone", &Command{Type: CommandBuildStructure, StructureType: StructureCommercialZone, X: 10, Y: 8}},
+		{"build power plant", &Command{Type: CommandBuildStructure, StructureType: StructurePowerPlantSolar, X: 24, Y: 15}},
+		{"build road", &Command{Type: CommandBuildRoad, X: 20, Y: 12, ToX: 20, ToY: 28}},
+		{"build power line", &Command{Type: CommandBuildPowerLine, X: 4, Y: 12, ToX: 4, ToY: 28}},
+		{"bulldoze zone", &Command{Type: CommandBulldoze, X: 5, Y: 7}},
+		{"bulldoze power plant", &Command{Type: CommandBulldoze, X: 12, Y: 13}},
+		{"bulldoze road", &Command{Type: CommandBulldoze, X: 20, Y: 10}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestCity(t)
+			before := takeSnapshot()
+
+			err := ApplyCommand(test.command)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after := takeSnapshot()
+			if len(after.zones) == len(before.zones) && len(after.plants) == len(before.plants) && after.funds == before.funds {
+				t.Fatal("command did not modify the city")
+			}
+
+			err = ApplyCommand(&Command{Type: CommandUndo})
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareSnapshot(t, "undo", before)
+
+			err = ApplyCommand(&Command{Type: CommandRedo})
+			if err != nil {
+				t.Fatal(err)
+			}
+			compareSnapshot(t, "redo", after)
+
+			err = ApplyCommand(&Command{Type: CommandRedo})
+			if !errors.Is(err, ErrNothingToRedo) {
+				t.Errorf("expected ErrNothingToRedo, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUndoExpires(t *testing.T) {
+	build := &Command{Type: CommandBuildStructure, StructureType: StructureCommercialZone, X: 10, Y: 8}
+
+	// Operations may be undone until the end of the month.
+	newTestCity(t)
+	before := takeSnapshot()
+	err := ApplyCommand(build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	World.Ticks = MonthTicks - 1
+	err = ApplyCommand(&Command{Type: CommandUndo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareSnapshot(t, "undo", before)
+
+	// Undone operations may not be redone after the end of the month.
+	World.Ticks = MonthTicks
+	err = ApplyCommand(&Command{Type: CommandRedo})
+	if !errors.Is(err, ErrNothingToRedo) {
+		t.Fatalf("expected ErrNothingToRedo, got %v", err)
+	}
+	compareSnapshot(t, "redo", before)
+
+	// Operations made before the current month may not be undone.
+	err = ApplyCommand(build)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := takeSnapshot()
+	World.Ticks = MonthTicks * 2
+	err = ApplyCommand(&Command{Type: CommandUndo})
+	if !errors.Is(err, ErrNothingToUndo) {
+		t.Fatalf("expected ErrNothingToUndo, got %v", err)
+	}
+	compareSnapshot(t, "undo", after)
+}
@@ -13,17 +13,10 @@ import (
 	"sync"
 	"time"
 
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
-
-	"github.com/hajimehoshi/ebiten/v2/audio"
-
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 
 	"code.rocketnine.space/tslocum/citylimits/asset"
-	"code.rocketnine.space/tslocum/citylimits/component"
-	"code.rocketnine.space/tslocum/gohan"
-	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/lafriks/go-tiled"
 )
 
@@ -38,7 +31,26 @@ const (
 
 const TileSize = 64
 
-var DirtTile = uint32(9*32 + (0))
+// tilesetFirstGID is the first GID of the tileset shared by all maps.
+const tilesetFirstGID = 1
+
+// Tileset indexes.
+var (
+	RoadTile   = uint32(0)
+	DirtTile   = uint32(9*32 + (0))
+	BridgeTile = uint32(4*32 + (16))
+	FireTile   = uint32(16*32 + (15))
+
+	PowerLineTile       = uint32(3*32 + (31))
+	HighVoltageLineTile = uint32(7*32 + (31))
+)
+
+// Sound effects.
+const (
+	SoundBuild = iota + 1
+	SoundBulldozeTree
+	SoundDemolish
+)
 
 const startingFunds = 10000
 
@@ -48,24 +60,20 @@ const SidebarWidth = 199
 
 var (
 	GrassTile = uint32(11*32 + (0))
+	WaterTile = uint32(8*32 + (16))
+	RockTile  = uint32(3*32 + (0))
+	SandTile  = uint32(14*32 + (0))
 	TreeTileA = uint32(5*32 + (24))
 	TreeTileB = uint32(5*32 + (25))
 )
 
-type HUDButton struct {
-	Sprite                       *ebiten.Image
-	SpriteOffsetX, SpriteOffsetY float64
-	Label                        string
-	StructureType                int
-}
-
-var HUDButtons []*HUDButton
-
 var CameraMinZoom = 0.1
 var CameraMaxZoom = 1.0
 
 const startingTax = 0.12
 
+const startingFunding = 1.0
+
 var World = &GameWorld{
 	CamScale:       startingZoom,
 	CamScaleTarget: startingZoom,
@@ -74,17 +82,23 @@ var World = &GameWorld{
 	PlayerWidth:  8,
 	PlayerHeight: 32,
 
-	TileImages: make(map[uint32]*ebiten.Image),
-	ResetGame:  true,
-	Level:      NewLevel(256),
+	ResetGame: true,
+	Level:     NewLevel(DefaultMapSize, DefaultMapSize),
+	MapWidth:  DefaultMapSize,
+	MapHeight: DefaultMapSize,
 
-	Power:     newPowerMap(),
-	PowerOuts: newPowerOuts(),
+	Power:     newPowerMap(DefaultMapSize, DefaultMapSize),
+	Pollution: newPollutionMap(DefaultMapSize, DefaultMapSize),
+	PowerOuts: newPowerOuts(DefaultMapSize, DefaultMapSize),
+	Brownouts: newPowerOuts(DefaultMapSize, DefaultMapSize),
 
 	TaxR: startingTax,
 	TaxC: startingTax,
 	TaxI: startingTax,
 
+	PoliceFunding: startingFunding,
+	FireFunding:   startingFunding,
+
 	BuildDragX: -1,
 	BuildDragY: -1,
 	LastBuildX: -1,
@@ -93,22 +107,31 @@ var World = &GameWorld{
 	Printer: message.NewPrinter(language.English),
 }
 
+// MaxZonePopulation is the population of a fully developed zone.
+const MaxZonePopulation = 10
+
 type Zone struct {
 	Type       int // StructureResidentialZone, StructureCommercialZone or StructureIndustrialZone
 	X, Y       int
 	Population int
 	Powered    bool
+	PowerRatio float64 // Fraction of the power required which is supplied
+	PowerLoss  int     // Transmission loss of the power supplied, in tenths of a percent
+	Crime      int     // Crime rate, up to MaxCrime
 }
 
 type PowerPlant struct {
 	Type int
 	X, Y int
+	Age  int // Age in months
 }
 
 type GameWorld struct {
 	Level *GameLevel
 
-	Player gohan.Entity
+	MapWidth, MapHeight int    // Size of new maps
+	TerrainPreset       string // Terrain preset of new maps
+	MapSeed             int64  // Seed of new maps, or 0 to use a random seed
 
 	ScreenW, ScreenH int
 
@@ -136,34 +159,33 @@ type GameWorld struct {
 	HoverLastX, HoverLastY int
 	HoverValid             bool
 
-	Map             *tiled.Map
-	ObjectGroups    []*tiled.ObjectGroup
-	HazardRects     []image.Rectangle
-	CreepRects      []image.Rectangle
-	CreepEntities   []gohan.Entity
-	TriggerEntities []gohan.Entity
-	TriggerRects    []image.Rectangle
-	TriggerNames    []string
+	Map          *tiled.Map
+	ObjectGroups []*tiled.ObjectGroup
+	HazardRects  []image.Rectangle
+	CreepRects   []image.Rectangle
+	TriggerRects []image.Rectangle
+	TriggerNames []string
 
 	NativeResolution bool
 
-	BrokenPieceA, BrokenPieceB gohan.Entity
+	LoadFile string // City to load when starting the game
+
+	AutosaveMonths int    // Months between autosaves, or 0 to save only when the game exits
+	RecoveryFile   string // City offered for recovery after a session ended abnormally
 
-	TileImages         map[uint32]*ebiten.Image
-	TileImagesFirstGID uint32
+	ExitGame bool
 
 	ResetGame bool
 
 	MuteMusic        bool
-	MuteSoundEffects bool // TODO
+	MuteSoundEffects bool
+	SoundEffects     []int // Sound effects to be played by the game
 
 	GotCursorPosition bool
 
-	tilesets []*ebiten.Image
-
 	EnvironmentSprites int
 
-	SelectedStructure *Structure
+	SelectedStructure *Structure // Structure under the cursor while no structure is selected for building
 
 	HUDUpdated     bool
 	HUDButtonRects []image.Rectangle
@@ -172,20 +194,31 @@ type GameWorld struct {
 	RCIWindowRect image.Rectangle
 	ShowRCIWindow bool
 
+	PowerButtonRect image.Rectangle
+
 	HelpUpdated     bool
 	HelpPage        int
 	HelpButtonRects []image.Rectangle
 
 	PowerPlants []*PowerPlant
 	Zones       []*Zone
+	Fires       []*Fire
+
+	Structures   []*Structure   // Placed structures, except roads
+	structureMap [][]*Structure // Structure occupying each tile, indexed by x and y
 
 	HavePowerOut bool
 	PowerOuts    [][]bool
+	HaveBrownout bool
+	Brownouts    [][]bool // Zones supplied with only part of the power they require
 
 	Ticks int
 
 	Paused bool
 
+	Seed int64      // Seed of the simulation's random number generator
+	Rand *rand.Rand // Random number generator used by the simulation
+
 	Funds int
 
 	Printer *message.Printer
@@ -195,10 +228,29 @@ type GameWorld struct {
 	Messages      []string
 	MessagesTicks []int
 
+	Commands []*Command // Queued commands
+
+	undoHistory []*undoRecord
+
+	RecordFile  string // Replay file to record to
+	ReplayFile  string // Replay file to play back
+	Replaying   bool
+	ReplayEnd   int // Tick at which the replay ends
+	ReplaySpeed int // Ticks simulated per update during a replay
+	recording   *savedReplay
+	redoHistory []*undoRecord
+
+	Pollution PollutionMap
+
 	Power          PowerMap
 	PowerUpdated   bool
 	PowerAvailable int
 	PowerNeeded    int
+	PowerLost      int // Power lost in transmission
+
+	powerNetworksChanged bool // Whether the power networks must be labeled again
+	powerNetworks        int  // Last power network label assigned
+	powerLossScans       int  // Last transmission loss scan
 
 	BuildDragX int
 	BuildDragY int
@@ -210,7 +262,10 @@ type GameWorld struct {
 	TaxC float64
 	TaxI float64
 
-	playingSong int
+	PoliceFunding float64 // Fraction of police station upkeep paid
+	FireFunding   float64 // Fraction of fire station upkeep paid
+
+	PowerPriority int // Zone type supplied with power first, or 0 to share power fairly
 
 	resetTipShown bool
 }
@@ -218,30 +273,51 @@ type GameWorld struct {
 var ErrNothingToBulldoze = errors.New("nothing to bulldoze")
 
 func Reset() {
-	for _, e := range gohan.AllEntities() {
-		e.Remove()
+	World.Seed = World.MapSeed
+	if World.Seed == 0 {
+		World.Seed = time.Now().UnixNano()
 	}
-	World.Player = 0
-
-	rand.Seed(time.Now().UnixNano())
+	World.Rand = rand.New(rand.NewSource(World.Seed))
 
+	World.Ticks = 0
 	World.Funds = startingFunds
+	World.TaxR, World.TaxC, World.TaxI = startingTax, startingTax, startingTax
+	World.PoliceFunding = startingFunding
+	World.FireFunding = startingFunding
+	World.PowerPriority = 0
+	World.Commands = nil
+	World.SoundEffects = nil
+	clearUndoHistory()
 
 	World.ObjectGroups = nil
 	World.HazardRects = nil
 	World.CreepRects = nil
-	World.CreepEntities = nil
-	World.TriggerEntities = nil
 	World.TriggerRects = nil
 	World.TriggerNames = nil
 
-	World.CamX = float64((32 * TileSize) - rand.Intn(64*TileSize))
-	World.CamY = float64((32 * TileSize) + rand.Intn(32*TileSize))
+	World.Level = NewLevel(World.MapWidth, World.MapHeight)
+	World.Power = newPowerMap(World.MapWidth, World.MapHeight)
+	World.powerNetworksChanged = true
+	World.Pollution = newPollutionMap(World.MapWidth, World.MapHeight)
+	ResetPowerOuts()
+
+	World.Zones = nil
+	World.PowerPlants = nil
+	World.Fires = nil
+	rebuildStructures()
 
-	World.playingSong = rand.Intn(3)
+	World.CamX = float64((World.MapWidth / 8 * TileSize) - World.Rand.Intn(World.MapWidth/4*TileSize))
+	World.CamY = float64(((World.MapWidth + World.MapHeight) / 16 * TileSize) + World.Rand.Intn((World.MapWidth+World.MapHeight)/16*TileSize))
 }
 
+// mapCache holds parsed structure maps.
+var mapCache = make(map[int]*tiled.Map)
+
 func LoadMap(structureType int) (*tiled.Map, error) {
+	if m := mapCache[structureType]; m != nil {
+		return m, nil
+	}
+
 	filePath := StructureFilePaths[structureType]
 	if filePath == "" {
 		panic(fmt.Sprintf("unknown structure %d", structureType))
@@ -253,85 +329,30 @@ func LoadMap(structureType int) (*tiled.Map, error) {
 		log.Fatalf("error parsing world: %+v", err)
 	}
 
+	mapCache[structureType] = m
 	return m, err
 }
 
-func DrawMap(structureType int) *ebiten.Image {
-	img := ebiten.NewImage(128, 128)
-
-	m, err := LoadMap(structureType)
-	if err != nil {
-		panic(err)
-	}
-
-	var t *tiled.LayerTile
-	for i, layer := range m.Layers {
-		for y := 0; y < m.Height; y++ {
-			for x := 0; x < m.Width; x++ {
-				t = layer.Tiles[y*m.Width+x]
-				if t == nil || t.Nil {
-					continue // No tile at this position.
-				}
-
-				tileImg := World.TileImages[t.Tileset.FirstGID+t.ID]
-				if tileImg == nil {
-					continue
-				}
-
-				xi, yi := CartesianToIso(float64(x), float64(y))
-
-				scale := 0.9 / float64(m.Width)
-				if m.Width < 2 {
-					scale = 0.6
-				}
-
-				paddingX := 64.0
-				op := &ebiten.DrawImageOptions{}
-				op.GeoM.Translate(xi+(paddingX*(float64(m.Width)-1)), (yi+float64(i*-40))+92)
-				op.GeoM.Scale(scale, scale)
-				img.DrawImage(tileImg, op)
-			}
-		}
-	}
-
-	return img
+// TileGID returns the GID of the tile at the specified index of the tileset.
+func TileGID(id uint32) uint32 {
+	return tilesetFirstGID + id
 }
 
-func LoadTileset() error {
-	m, err := LoadMap(StructureResidentialLow)
-	if err != nil {
-		return err
-	}
-
-	// Load tileset.
-
-	if len(World.tilesets) != 0 {
-		return nil // Already loaded.
-	}
-
+// LoadTilesetImage decodes the image of the tileset shared by all maps.
+func LoadTilesetImage(m *tiled.Map) (*tiled.Tileset, image.Image, error) {
 	tileset := m.Tilesets[0]
 	imgPath := filepath.Join("./image/tileset/", tileset.Image.Source)
 	f, err := asset.FS.Open(filepath.ToSlash(imgPath))
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 	defer f.Close()
 
 	img, _, err := image.Decode(f)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
-	World.tilesets = append(World.tilesets, ebiten.NewImageFromImage(img))
-
-	// Load tiles.
-
-	for i := uint32(0); i < uint32(tileset.TileCount); i++ {
-		rect := tileset.GetTileRect(i)
-		World.TileImages[i+tileset.FirstGID] = World.tilesets[0].SubImage(rect).(*ebiten.Image)
-	}
-
-	World.TileImagesFirstGID = tileset.FirstGID
-	return nil
+	return tileset, img, nil
 }
 
 func ShowBuildCost(structureType int, cost int) {
@@ -343,15 +364,22 @@ func ShowBuildCost(structureType int, cost int) {
 		ShowMessage(World.Printer.Sprintf("Zoned area for commercial use (-$%d)", cost), 3)
 	} else if structureType == StructureIndustrialZone {
 		ShowMessage(World.Printer.Sprintf("Zoned area for industrial use (-$%d)", cost), 3)
+	} else if structureType == StructureRaiseTerrain {
+		ShowMessage(World.Printer.Sprintf("Raised terrain (-$%d)", cost), 3)
+	} else if structureType == StructureLowerTerrain {
+		ShowMessage(World.Printer.Sprintf("Lowered terrain (-$%d)", cost), 3)
 	} else {
-		ShowMessage(World.Printer.Sprintf("Built %s (-$%d)", strings.ToLower(StructureTooltips[World.HoverStructure]), cost), 3)
+		ShowMessage(World.Printer.Sprintf("Built %s (-$%d)", strings.ToLower(StructureTooltips[structureType]), cost), 3)
 	}
 }
 
-func bulldozeArea(x int, y int, size int) {
-	for dx := 0; dx < size; dx++ {
-		for dy := 0; dy < size; dy++ {
-			BuildStructure(StructureBulldozer, false, x-dx, y-dy, true)
+// bulldozeStructure removes a structure from the structure registry and
+// bulldozes every tile it occupies.
+func bulldozeStructure(s *Structure) {
+	unregisterStructure(s)
+	for dx := 0; dx < s.Width; dx++ {
+		for dy := 0; dy < s.Height; dy++ {
+			BuildStructure(StructureBulldozer, false, s.X-dx, s.Y-dy, true)
 		}
 	}
 }
@@ -374,142 +402,132 @@ func BuildStructure(structureType int, hover bool, placeX int, placeY int, inter
 	w := m.Width - 1
 	h := m.Height - 1
 
-	if placeX-w < 0 || placeY-h < 0 || placeX >= 256 || placeY >= 256 {
+	if placeX-w < 0 || placeY-h < 0 || !ValidXY(placeX, placeY) {
 		return nil, errors.New("invalid location: building does not fit")
 	}
 
 	structure := &Structure{
-		Type: structureType,
-		X:    placeX,
-		Y:    placeY,
+		Type:   structureType,
+		X:      placeX,
+		Y:      placeY,
+		Width:  m.Width,
+		Height: m.Height,
 	}
 
 	if structureType == StructureBulldozer && !hover {
-		// TODO bulldoze entire structure, remove from zones
+		if s := World.structureMap[placeX][placeY]; s != nil && !internal {
+			bulldozeStructure(s)
+			PlaySoundEffect(SoundDemolish)
+			return s, nil
+		}
+
 		var bulldozed bool
 		for i := range World.Level.Tiles {
-			if World.Level.Tiles[i][placeX][placeY].Sprite != nil {
-				World.Level.Tiles[i][placeX][placeY].Sprite = nil
+			if World.Level.Tiles[i][placeX][placeY].Sprite != 0 {
+				World.Level.Tiles[i][placeX][placeY].Sprite = 0
 				bulldozed = true
 			}
 
-			var img *ebiten.Image
+			var gid uint32
 			if i == 0 {
-				img = World.TileImages[DirtTile+World.TileImagesFirstGID]
+				gid = TileGID(DirtTile)
+				if World.Level.IsWater(placeX, placeY) {
+					gid = TileGID(WaterTile)
+				}
+				World.Level.Tiles[i][placeX][placeY].Structure = 0
 			}
-			if World.Level.Tiles[i][placeX][placeY].EnvironmentSprite != img {
-				bulldozeTree := World.Level.Tiles[i][placeX][placeY].EnvironmentSprite == World.TileImages[TreeTileA+World.TileImagesFirstGID] || World.Level.Tiles[i][placeX][placeY].EnvironmentSprite == World.TileImages[TreeTileB+World.TileImagesFirstGID]
+			if World.Level.Tiles[i][placeX][placeY].EnvironmentSprite != gid {
+				bulldozeTree := World.Level.Tiles[i][placeX][placeY].EnvironmentSprite == TileGID(TreeTileA) || World.Level.Tiles[i][placeX][placeY].EnvironmentSprite == TileGID(TreeTileB)
 				if bulldozeTree {
-					sounds := []*audio.Player{
-						asset.SoundPop1,
-						asset.SoundPop4,
-						asset.SoundPop5,
-					}
-					sound := sounds[rand.Intn(len(sounds))]
-					sound.Rewind()
-					sound.Play()
+					PlaySoundEffect(SoundBulldozeTree)
 				}
 
-				World.Level.Tiles[i][placeX][placeY].EnvironmentSprite = img
+				World.Level.Tiles[i][placeX][placeY].EnvironmentSprite = gid
 				bulldozed = true
 			}
 		}
 		if !bulldozed {
 			return nil, ErrNothingToBulldoze
 		}
-		if !internal {
-			var bulldozeStructure bool
-			checkSpaces := 2
-		REMOVEZONES:
-			for i, zone := range World.Zones {
-				for dx := 0; dx < checkSpaces; dx++ {
-					for dy := 0; dy < checkSpaces; dy++ {
-						if placeX == zone.X-dx && placeY == zone.Y-dy {
-							World.Zones = append(World.Zones[:i], World.Zones[i+1:]...)
-							bulldozeArea(zone.X, zone.Y, 2)
-							bulldozeStructure = true
-							break REMOVEZONES
-						}
-					}
-				}
-			}
-			checkSpaces = 5
-		REMOVEPOWER:
-			for i, plant := range World.PowerPlants {
-				for dx := 0; dx < checkSpaces; dx++ {
-					for dy := 0; dy < checkSpaces; dy++ {
-						if placeX == plant.X-dx && placeY == plant.Y-dy {
-							World.PowerPlants = append(World.PowerPlants[:i], World.PowerPlants[i+1:]...)
-							bulldozeArea(plant.X, plant.Y, 5)
-							bulldozeStructure = true
-							World.PowerUpdated = true
-							break REMOVEPOWER
-						}
-					}
-				}
-			}
-			if bulldozeStructure {
-				sounds := []*audio.Player{
-					asset.SoundExplosion1,
-					asset.SoundExplosion2,
-				}
-				sound := sounds[rand.Intn(len(sounds))]
-				sound.Rewind()
-				sound.Play()
-			}
-		}
 		World.Power.SetTile(placeX, placeY, false)
 		return structure, nil
 	}
 
-	createTileEntity := func(t *tiled.LayerTile, x float64, y float64) gohan.Entity {
-		mapTile := gohan.NewEntity()
-		mapTile.AddComponent(&component.Position{
-			X: x,
-			Y: y,
-		})
-
-		mapTile.AddComponent(&component.Sprite{
-			Image:          World.TileImages[t.Tileset.FirstGID+t.ID],
-			HorizontalFlip: t.HorizontalFlip,
-			VerticalFlip:   t.VerticalFlip,
-			DiagonalFlip:   t.DiagonalFlip,
-		})
-
-		return mapTile
+	terraformTool := IsTerraformTool(structureType)
+	if terraformTool && !hover {
+		err := terraform(placeX, placeY, structureType == StructureRaiseTerrain)
+		if err != nil {
+			return nil, err
+		}
+		return structure, nil
 	}
-	_ = createTileEntity
-
-	// TODO Add entity
 
 	tileOccupied := func(tx int, ty int) bool {
-		return World.Level.Tiles[1][tx][ty].Sprite != nil || (World.Level.Tiles[0][tx][ty].Sprite != nil && (structureType != StructureRoad || World.Level.Tiles[0][tx][ty].Sprite != World.TileImages[World.TileImagesFirstGID]))
+		if World.Level.Tiles[0][tx][ty].Structure == StructurePowerLine && (structureType == StructureRoad || structureType == StructureHighVoltageLine) {
+			// Roads may be built across power lines, and power lines may be
+			// upgraded to high-voltage lines, replacing them.
+			return false
+		}
+		if World.Level.Tiles[0][tx][ty].Structure == StructureHighVoltageLine && structureType == StructureRoad {
+			return false
+		}
+		return World.Level.Tiles[1][tx][ty].Sprite != 0 || (World.Level.Tiles[0][tx][ty].Sprite != 0 && (structureType != StructureRoad || World.Level.Tiles[0][tx][ty].Structure != StructureRoad))
 	}
 
 	valid := true
 	var existingRoadTiles int
+	var onWater bool
+	minHeight, maxHeight := MaxElevation, 0
 VALIDBUILD:
 	for y := 0; y < m.Height; y++ {
 		for x := 0; x < m.Width; x++ {
 			tx, ty := (x+placeX)-w, (y+placeY)-h
-			if structureType == StructureRoad && World.Level.Tiles[0][tx][ty].Sprite == World.TileImages[World.TileImagesFirstGID] {
+			if th := World.Level.Tiles[0][tx][ty].Height; th < minHeight {
+				minHeight = th
+			}
+			if th := World.Level.Tiles[0][tx][ty].Height; th > maxHeight {
+				maxHeight = th
+			}
+			if structureType == StructureRoad && World.Level.Tiles[0][tx][ty].Structure == StructureRoad {
 				existingRoadTiles++
 			}
 			if tileOccupied(tx, ty) && structureType != StructureBulldozer {
 				valid = false
 				break VALIDBUILD
 			}
+			if World.Level.IsWater(tx, ty) && structureType != StructureRoad && structureType != StructureBulldozer {
+				// Only roads may be built over water, as bridges.
+				valid = false
+				onWater = true
+				break VALIDBUILD
+			}
 		}
 	}
 	if structureType == StructureRoad && existingRoadTiles == 4 {
 		valid = false
 	}
+	var steep bool
+	if structureType == StructureRoad {
+		steep = maxHeight-minHeight > MaxRoadSlope
+	} else if structureType != StructureBulldozer && !terraformTool {
+		// Buildings require flat ground.
+		steep = maxHeight != minHeight
+	}
+	if steep {
+		valid = false
+	}
 	if hover {
 		if structureType == StructureBulldozer {
 			World.HoverValid = true
 		} else {
 			World.HoverValid = valid
 		}
+	} else if onWater {
+		return nil, errors.New("invalid location: cannot build on water")
+	} else if steep && structureType == StructureRoad {
+		return nil, errors.New("invalid location: too steep for a road")
+	} else if steep {
+		return nil, errors.New("invalid location: ground is not flat")
 	} else if !valid {
 		return nil, errors.New("invalid location: space already occupied")
 	}
@@ -519,18 +537,33 @@ VALIDBUILD:
 			tx, ty := (x+placeX)-w, (y+placeY)-h
 			if hover {
 				if !tileOccupied(tx, ty) || structureType == StructureBulldozer {
-					if structureType != StructureBulldozer {
-						World.Level.Tiles[0][tx][ty].HoverSprite = World.TileImages[World.TileImagesFirstGID]
+					if structureType != StructureBulldozer && !IsPowerLine(structureType) && !terraformTool {
+						World.Level.Tiles[0][tx][ty].HoverSprite = TileGID(RoadTile)
 					}
 					// Hide environment sprites temporarily.
 					for i := 1; i < len(World.Level.Tiles); i++ {
-						World.Level.Tiles[i][tx][ty].HoverSprite = asset.ImgBlank
+						World.Level.Tiles[i][tx][ty].HoverSprite = HiddenTile
 					}
 				}
 			} else {
-				World.Level.Tiles[0][tx][ty].Sprite = World.TileImages[World.TileImagesFirstGID]
-				World.Level.Tiles[0][tx][ty].EnvironmentSprite = nil
-				World.Level.Tiles[1][tx][ty].EnvironmentSprite = nil
+				if IsPowerLine(World.Level.Tiles[0][tx][ty].Structure) {
+					// Remove the power line being replaced.
+					for i := 1; i < len(World.Level.Tiles); i++ {
+						World.Level.Tiles[i][tx][ty].Sprite = 0
+					}
+				}
+				if IsPowerLine(structureType) {
+					// Power lines are built above the ground.
+					World.Level.Tiles[0][tx][ty].Structure = structureType
+					World.Level.Tiles[1][tx][ty].EnvironmentSprite = 0
+					continue
+				}
+				World.Level.Tiles[0][tx][ty].Sprite = TileGID(RoadTile)
+				if !World.Level.IsWater(tx, ty) {
+					World.Level.Tiles[0][tx][ty].EnvironmentSprite = 0
+				}
+				World.Level.Tiles[0][tx][ty].Structure = structureType
+				World.Level.Tiles[1][tx][ty].EnvironmentSprite = 0
 			}
 		}
 	}
@@ -544,11 +577,6 @@ VALIDBUILD:
 					continue // No tile at this position.
 				}
 
-				tileImg := World.TileImages[t.Tileset.FirstGID+t.ID]
-				if tileImg == nil {
-					continue
-				}
-
 				layerNum := i
 				if structureType != StructureRoad {
 					layerNum++
@@ -559,19 +587,22 @@ VALIDBUILD:
 				}
 
 				tx, ty := (x+placeX)-w, (y+placeY)-h
+				gid := t.Tileset.FirstGID + t.ID
+				if structureType == StructureRoad && World.Level.IsWater(tx, ty) {
+					gid = TileGID(BridgeTile)
+				}
 				if hover {
 					if !tileOccupied(tx, ty) || structureType == StructureBulldozer {
-						World.Level.Tiles[layerNum][tx][ty].HoverSprite = World.TileImages[t.Tileset.FirstGID+t.ID]
+						World.Level.Tiles[layerNum][tx][ty].HoverSprite = gid
 					}
 				} else {
-					World.Level.Tiles[layerNum][tx][ty].Sprite = World.TileImages[t.Tileset.FirstGID+t.ID]
+					World.Level.Tiles[layerNum][tx][ty].Sprite = gid
 
-					if structureType == StructureRoad {
+					if structureType == StructureRoad || IsPowerLine(structureType) {
 						World.Power.SetTile(tx, ty, true)
 					}
 
-					isZone := structureType == StructureResidentialZone || structureType == StructureCommercialZone || structureType == StructureIndustrialZone
-					if isZone || structureType == StructurePowerPlantCoal || structureType == StructureBulldozer {
+					if IsZone(structureType) || IsPowerPlant(structureType) || IsPowerLine(structureType) || structureType == StructureBulldozer {
 						World.PowerUpdated = true
 					}
 				}
@@ -640,19 +671,17 @@ func ScreenToIso(x, y int) (float64, float64) {
 	return ((float64(x) - cx) / World.CamScale) + World.CamX, ((float64(y) - cy) / World.CamScale) + World.CamY
 }
 
+// ScreenToCartesian returns the location of the tile at the specified screen
+// position, accounting for the elevation of the terrain.
 func ScreenToCartesian(x, y int) (float64, float64) {
 	xi, yi := ScreenToIso(x, y)
-	return IsoToCartesian(xi, yi)
-}
-
-func HUDButtonAt(x, y int) *HUDButton {
-	point := image.Point{x, y}
-	for i, rect := range World.HUDButtonRects {
-		if point.In(rect) {
-			return HUDButtons[i]
+	for h := MaxElevation; h > 0; h-- {
+		tx, ty := IsoToCartesian(xi, yi+float64(h*ElevationHeight))
+		if tx >= 0 && ty >= 0 && World.Level.HeightAt(int(tx), int(ty)) == h {
+			return tx, ty
 		}
 	}
-	return nil
+	return IsoToCartesian(xi, yi)
 }
 
 func HelpButtonAt(x, y int) int {
@@ -669,69 +698,12 @@ func AltButtonAt(x, y int) int {
 	point := image.Point{x, y}
 	if point.In(World.RCIButtonRect) {
 		return 0
+	} else if point.In(World.PowerButtonRect) {
+		return 1
 	}
 	return -1
 }
 
-func HandleRCIWindow(x, y int) bool {
-	if !World.ShowRCIWindow {
-		return false
-	}
-
-	point := image.Point{x, y}
-	if !point.In(World.RCIWindowRect) {
-		return false
-	}
-
-	if !ebiten.IsMouseButtonPressed(ebiten.MouseButtonLeft) {
-		return true
-	}
-
-	var updated bool
-	barRectR := image.Rect(World.RCIWindowRect.Min.X+381, World.RCIWindowRect.Min.Y, World.RCIWindowRect.Min.X+575, World.RCIWindowRect.Min.Y+50)
-	barRectC := image.Rect(World.RCIWindowRect.Min.X+381, World.RCIWindowRect.Min.Y+50, World.RCIWindowRect.Min.X+575, World.RCIWindowRect.Min.Y+100)
-	barRectI := image.Rect(World.RCIWindowRect.Min.X+381, World.RCIWindowRect.Min.Y+100, World.RCIWindowRect.Min.X+575, World.RCIWindowRect.Max.Y)
-	if point.In(barRectR) {
-		World.TaxR = float64(x-barRectR.Min.X) / float64(barRectR.Dx())
-		if World.TaxR >= .99 {
-			World.TaxR = 1.0
-		}
-		World.HUDUpdated = true
-		updated = true
-	} else if point.In(barRectC) {
-		World.TaxC = float64(x-barRectC.Min.X) / float64(barRectC.Dx())
-		if World.TaxC >= .99 {
-			World.TaxC = 1.0
-		}
-		World.HUDUpdated = true
-		updated = true
-	} else if point.In(barRectI) {
-		World.TaxI = float64(x-barRectI.Min.X) / float64(barRectI.Dx())
-		if World.TaxI >= .99 {
-			World.TaxI = 1.0
-		}
-		World.HUDUpdated = true
-		updated = true
-	}
-	if !updated {
-		return true
-	}
-
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButtonLeft) || World.Ticks%16 == 0 {
-		sounds := []*audio.Player{
-			asset.SoundPop1,
-			asset.SoundPop2,
-			asset.SoundPop3,
-			asset.SoundPop4,
-			asset.SoundPop5,
-		}
-		sound := sounds[rand.Intn(len(sounds))]
-		sound.Rewind()
-		sound.Play()
-	}
-	return true
-}
-
 func SetHoverStructure(structureType int) {
 	World.HoverStructure = structureType
 	World.HUDUpdated = true
@@ -794,6 +766,12 @@ var StructureTooltips = map[int]string{
 	StructureResidentialZone:             "Residential zone",
 	StructureCommercialZone:              "Commercial zone",
 	StructureIndustrialZone:              "Industrial zone",
+	StructureRaiseTerrain:                "Raise terrain",
+	StructureLowerTerrain:                "Lower terrain",
+	StructureFireStation:                 "Fire station",
+	StructurePowerLine:                   "Power line",
+	StructureHighVoltageLine:             "High-voltage line",
+	StructureRubble:                      "Rubble",
 }
 
 var StructureCosts = map[int]int{
@@ -806,14 +784,87 @@ var StructureCosts = map[int]int{
 	StructureResidentialZone:   100,
 	StructureCommercialZone:    200,
 	StructureIndustrialZone:    100,
+	StructureRaiseTerrain:      50,
+	StructureLowerTerrain:      50,
+	StructureFireStation:       1000,
+	StructurePowerLine:         10,
+	StructureHighVoltageLine:   30,
+}
+
+// BridgeCost is the cost of building a road where any of its tiles are over water.
+const BridgeCost = 100
+
+// BuildCost returns the cost of building a structure at the specified location.
+func BuildCost(structureType int, x int, y int) int {
+	if structureType != StructureRoad {
+		return StructureCosts[structureType]
+	}
+
+	m, err := LoadMap(structureType)
+	if err != nil {
+		return StructureCosts[structureType]
+	}
+	for dx := 0; dx < m.Width; dx++ {
+		for dy := 0; dy < m.Height; dy++ {
+			if World.Level.IsWater(x-dx, y-dy) {
+				return BridgeCost
+			}
+		}
+	}
+	return StructureCosts[structureType]
+}
+
+// structureTooltip returns a description of a placed structure.
+func structureTooltip(s *Structure) string {
+	name := StructureTooltips[s.Type]
+	if name == "" {
+		return ""
+	}
+	if s.Zone != nil {
+		name += World.Printer.Sprintf("\nPopulation %d", s.Zone.Population)
+		if s.Zone.Population > 0 {
+			name += World.Printer.Sprintf("\nCrime %d%%", s.Zone.Crime)
+		}
+		if pollution := ZonePollution(s.Zone); pollution > 0 {
+			name += World.Printer.Sprintf("\nPollution %d%%", pollution)
+		}
+		if !s.Zone.Powered {
+			name += "\nNo power"
+		} else if s.Zone.PowerRatio < 1 {
+			name += World.Printer.Sprintf("\nBrownout (%d%% power)", int(s.Zone.PowerRatio*100))
+		}
+		if s.Zone.Powered && s.Zone.PowerLoss > 0 {
+			name += World.Printer.Sprintf("\nPower loss %.1f%%", float64(s.Zone.PowerLoss)/10)
+		}
+	} else if s.PowerPlant != nil {
+		if s.PowerPlant.Expired() {
+			name += "\nMust be rebuilt"
+		} else {
+			name += World.Printer.Sprintf("\nCapacity %d", s.PowerPlant.Capacity())
+			name += World.Printer.Sprintf("\nEfficiency %d%%", int(s.PowerPlant.Efficiency()*100))
+			name += World.Printer.Sprintf("\nCosts $%d/month", s.PowerPlant.Expenses())
+		}
+		name += World.Printer.Sprintf("\nAge %d years", s.PowerPlant.Age/12)
+	}
+	if FireAt(s) != nil {
+		name += "\nOn fire"
+	}
+	return name
 }
 
 func Tooltip() string {
+	if World.HoverStructure == 0 && World.SelectedStructure != nil {
+		return structureTooltip(World.SelectedStructure)
+	}
+
 	tooltipText := StructureTooltips[World.HoverStructure]
 	cost := StructureCosts[World.HoverStructure]
 	if cost > 0 {
 		tooltipText += World.Printer.Sprintf("\n$%d", cost)
 	}
+	if IsPowerPlant(World.HoverStructure) {
+		tooltipText += World.Printer.Sprintf("\n$%d/month", PowerPlantUpkeep[World.HoverStructure]+PowerPlantFuelCosts[World.HoverStructure])
+	}
 	return tooltipText
 }
 
@@ -885,7 +936,7 @@ func ShowMessage(message string, duration int) {
 }
 
 func ValidXY(x, y int) bool {
-	return x >= 0 && y >= 0 && x < 256 && y < 256
+	return x >= 0 && y >= 0 && x < World.Level.width && y < World.Level.height
 }
 
 var PowerPlantCapacities = map[int]int{
@@ -910,42 +961,30 @@ func IsPowerPlant(structureType int) bool {
 	return structureType == StructurePowerPlantCoal || structureType == StructurePowerPlantSolar || structureType == StructurePowerPlantNuclear
 }
 
-func IsZone(structureType int) bool {
-	return structureType == StructureResidentialZone || structureType == StructureCommercialZone || structureType == StructureIndustrialZone
+// IsPowerLine returns whether a structure type is a power line.
+func IsPowerLine(structureType int) bool {
+	return structureType == StructurePowerLine || structureType == StructureHighVoltageLine
 }
 
-func PlayNextSong() {
-	const numSongs = 3
-
-	asset.SoundMusic1.Pause()
-	asset.SoundMusic2.Pause()
-	asset.SoundMusic3.Pause()
+// IsTerraformTool returns whether a structure type raises or lowers terrain.
+func IsTerraformTool(structureType int) bool {
+	return structureType == StructureRaiseTerrain || structureType == StructureLowerTerrain
+}
 
-	World.playingSong++
-	if World.playingSong == numSongs {
-		World.playingSong = 0
-	}
+// IsService returns whether a structure type is a funded city service.
+func IsService(structureType int) bool {
+	return structureType == StructurePoliceStation || structureType == StructureFireStation
+}
 
-	switch World.playingSong {
-	case 0:
-		asset.SoundMusic1.Rewind()
-		asset.SoundMusic1.Play()
-	case 1:
-		asset.SoundMusic2.Rewind()
-		asset.SoundMusic2.Play()
-	case 2:
-		asset.SoundMusic3.Rewind()
-		asset.SoundMusic3.Play()
-	}
+func IsZone(structureType int) bool {
+	return structureType == StructureResidentialZone || structureType == StructureCommercialZone || structureType == StructureIndustrialZone
 }
 
-func ResumeSong() {
-	switch World.playingSong {
-	case 0:
-		asset.SoundMusic1.Play()
-	case 1:
-		asset.SoundMusic2.Play()
-	case 2:
-		asset.SoundMusic3.Play()
+// PlaySoundEffect requests the game to play a sound effect. Sound effects are
+// not played while sound effects are muted, including when running headless.
+func PlaySoundEffect(sound int) {
+	if World.MuteSoundEffects {
+		return
 	}
+	World.SoundEffects = append(World.SoundEffects, sound)
 }